@@ -0,0 +1,157 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Every encoded value starts with one of these bytes, so a reader can decode
+// entries written by any codec. That lets the configured codec be switched
+// without flushing Redis: old entries stay readable until they expire.
+const (
+	VersionJSON    byte = 0x01
+	VersionMsgpack byte = 0x02
+	VersionZstd    byte = 0x03
+)
+
+var ErrUnknownVersion = errors.New("unknown codec version")
+
+type Codec interface {
+	Version() byte
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Encode serializes v with c and prefixes the result with c's version byte.
+func Encode(c Codec, v any) (string, error) {
+	data, err := c.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 0, len(data)+1)
+	buf = append(buf, c.Version())
+	buf = append(buf, data...)
+	return string(buf), nil
+}
+
+// Decode reads the version byte of raw and unmarshals it with the matching
+// codec. Values written before versioning was introduced are plain JSON
+// objects and are decoded as such.
+func Decode(raw string, v any) error {
+	if len(raw) == 0 {
+		return fmt.Errorf("decode: %w", ErrUnknownVersion)
+	}
+
+	data := []byte(raw)
+	if data[0] == '{' {
+		return JSON{}.Unmarshal(data, v)
+	}
+
+	var c Codec
+	switch data[0] {
+	case VersionJSON:
+		c = JSON{}
+	case VersionMsgpack:
+		c = Msgpack{}
+	case VersionZstd:
+		c = defaultZstd
+	default:
+		return fmt.Errorf("decode: %w: 0x%02x", ErrUnknownVersion, data[0])
+	}
+
+	return c.Unmarshal(data[1:], v)
+}
+
+// ByName returns the codec configured by name: "json", "msgpack" or "zstd".
+func ByName(name string) (Codec, error) {
+	switch name {
+	case "json":
+		return JSON{}, nil
+	case "msgpack":
+		return Msgpack{}, nil
+	case "zstd":
+		return defaultZstd, nil
+	default:
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+}
+
+type JSON struct{}
+
+func (JSON) Version() byte { return VersionJSON }
+
+func (JSON) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSON) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// Msgpack reuses the json struct tags, so models need no extra annotations.
+type Msgpack struct{}
+
+func (Msgpack) Version() byte { return VersionMsgpack }
+
+func (Msgpack) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (Msgpack) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// Zstd compresses the MessagePack representation. It pays off for products
+// with long descriptions, where payloads shrink several times.
+type Zstd struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+}
+
+var defaultZstd = newZstd()
+
+func newZstd() *Zstd {
+	// With a nil writer/reader the encoder and decoder are only used through
+	// EncodeAll/DecodeAll, which are safe for concurrent use.
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	if err != nil {
+		panic(err)
+	}
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		panic(err)
+	}
+	return &Zstd{enc: enc, dec: dec}
+}
+
+func (*Zstd) Version() byte { return VersionZstd }
+
+func (z *Zstd) Marshal(v any) ([]byte, error) {
+	data, err := Msgpack{}.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return z.enc.EncodeAll(data, nil), nil
+}
+
+func (z *Zstd) Unmarshal(data []byte, v any) error {
+	raw, err := z.dec.DecodeAll(data, nil)
+	if err != nil {
+		return err
+	}
+	return Msgpack{}.Unmarshal(raw, v)
+}
@@ -0,0 +1,115 @@
+package codec
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// product has the shape of facade's cached products.
+type product struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Price       money     `json:"price"`
+	Category    string    `json:"category"`
+	CreatedAt   time.Time `json:"created_at"`
+	Images      []string  `json:"images"`
+}
+
+type money struct {
+	Amount   decimal.Decimal `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+var sample = product{
+	ID:          42,
+	Name:        "Sony WH-1000XM5",
+	Description: strings.Repeat("Беспроводные наушники с шумоподавлением. ", 50),
+	Price:       money{Amount: decimal.RequireFromString("399.99"), Currency: "USD"},
+	Category:    "Аудиотехника",
+	CreatedAt:   time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC),
+	Images:      []string{"https://minio/products/42/1.jpg", "https://minio/products/42/2.jpg"},
+}
+
+var codecs = []Codec{JSON{}, Msgpack{}, defaultZstd}
+
+func TestRoundTrip(t *testing.T) {
+	for _, c := range codecs {
+		raw, err := Encode(c, sample)
+		if err != nil {
+			t.Fatalf("codec 0x%02x: encode: %v", c.Version(), err)
+		}
+
+		var got product
+		if err := Decode(raw, &got); err != nil {
+			t.Fatalf("codec 0x%02x: decode: %v", c.Version(), err)
+		}
+		if got.ID != sample.ID || got.Description != sample.Description || !got.CreatedAt.Equal(sample.CreatedAt) ||
+			!got.Price.Amount.Equal(sample.Price.Amount) || got.Price.Currency != sample.Price.Currency {
+			t.Errorf("codec 0x%02x: got %+v", c.Version(), got)
+		}
+	}
+}
+
+func TestDecodeLegacyJSON(t *testing.T) {
+	raw, err := json.Marshal(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got product
+	if err := Decode(string(raw), &got); err != nil {
+		t.Fatalf("decode legacy value: %v", err)
+	}
+	if got.ID != sample.ID {
+		t.Errorf("expected id %d, got %d", sample.ID, got.ID)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	for _, c := range codecs {
+		b.Run(name(c), func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				raw, err := Encode(c, sample)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(raw)
+			}
+			b.ReportMetric(float64(size), "bytes/value")
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, c := range codecs {
+		raw, err := Encode(c, sample)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name(c), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var p product
+				if err := Decode(raw, &p); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func name(c Codec) string {
+	switch c.Version() {
+	case VersionJSON:
+		return "json"
+	case VersionMsgpack:
+		return "msgpack"
+	default:
+		return "zstd"
+	}
+}
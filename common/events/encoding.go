@@ -0,0 +1,170 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	eventsv1 "github.com/Riter/E-Shop/common/gen/go/events/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// HeaderContentType is the Kafka header naming the encoding of the value.
+const HeaderContentType = "content-type"
+
+type Encoding string
+
+const (
+	JSON     Encoding = "application/json"
+	Protobuf Encoding = "application/x-protobuf"
+)
+
+// Marshal encodes e; the result should be sent with HeaderContentType set
+// to enc.
+func Marshal(e ProductEvent, enc Encoding) ([]byte, error) {
+	switch enc {
+	case JSON:
+		return json.Marshal(e)
+	case Protobuf:
+		return proto.Marshal(toProto(e))
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", enc)
+	}
+}
+
+// Unmarshal decodes and validates an event. An empty contentType means JSON,
+// which is what producers without the header send.
+func Unmarshal(data []byte, contentType string) (ProductEvent, error) {
+	var e ProductEvent
+	switch Encoding(contentType) {
+	case "", JSON:
+		if err := json.Unmarshal(data, &e); err != nil {
+			return ProductEvent{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	case Protobuf:
+		var pb eventsv1.ProductEvent
+		if err := proto.Unmarshal(data, &pb); err != nil {
+			return ProductEvent{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		e = fromProto(&pb)
+	default:
+		return ProductEvent{}, fmt.Errorf("%w: unknown content type %q", ErrInvalid, contentType)
+	}
+
+	if err := e.Validate(); err != nil {
+		return ProductEvent{}, err
+	}
+	return e, nil
+}
+
+// UnmarshalRef decodes only the operation type and item ID of an event. It
+// accepts events whose item fails Validate, for consumers that don't read the
+// item.
+func UnmarshalRef(data []byte, contentType string) (Ref, error) {
+	var ref Ref
+	switch Encoding(contentType) {
+	case "", JSON:
+		var v struct {
+			Operation Operation `json:"operation_type"`
+			ItemID    int64     `json:"item_id"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return Ref{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		ref = Ref{Operation: v.Operation, ItemID: v.ItemID}
+	case Protobuf:
+		var pb eventsv1.ProductEvent
+		if err := proto.Unmarshal(data, &pb); err != nil {
+			return Ref{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		ref = Ref{Operation: Operation(pb.GetOperation()), ItemID: pb.GetItemId()}
+	default:
+		return Ref{}, fmt.Errorf("%w: unknown content type %q", ErrInvalid, contentType)
+	}
+
+	if !ref.Operation.Valid() {
+		return Ref{}, fmt.Errorf("%w: unknown operation type %d", ErrInvalid, ref.Operation)
+	}
+	if ref.ItemID <= 0 {
+		return Ref{}, fmt.Errorf("%w: item_id must be positive, got %d", ErrInvalid, ref.ItemID)
+	}
+	return ref, nil
+}
+
+func toProto(e ProductEvent) *eventsv1.ProductEvent {
+	pb := &eventsv1.ProductEvent{
+		SchemaVersion: uint32(e.SchemaVersion),
+		EventId:       e.EventID,
+		Operation:     eventsv1.Operation(e.Operation),
+		ItemId:        e.ItemID,
+	}
+	if !e.OccurredAt.IsZero() {
+		pb.OccurredAt = timestamppb.New(e.OccurredAt)
+	}
+
+	if e.Item != nil {
+		pb.Item = &eventsv1.Item{
+			Id:          e.Item.ID,
+			Name:        e.Item.Name,
+			Description: e.Item.Description,
+			Price:       e.Item.Price.String(),
+			Category:    e.Item.Category,
+			Images:      e.Item.Images,
+		}
+		if !e.Item.CreatedAt.IsZero() {
+			pb.Item.CreatedAt = timestamppb.New(e.Item.CreatedAt)
+		}
+		if e.Item.UpdatedAt != nil {
+			pb.Item.UpdatedAt = timestamppb.New(*e.Item.UpdatedAt)
+		}
+	}
+	return pb
+}
+
+func fromProto(pb *eventsv1.ProductEvent) ProductEvent {
+	e := ProductEvent{
+		SchemaVersion: int(pb.GetSchemaVersion()),
+		EventID:       pb.GetEventId(),
+		Operation:     Operation(pb.GetOperation()),
+		ItemID:        pb.GetItemId(),
+	}
+	if pb.OccurredAt != nil {
+		e.OccurredAt = pb.GetOccurredAt().AsTime()
+	}
+
+	if item := pb.GetItem(); item != nil {
+		e.Item = &Item{
+			ID:          item.GetId(),
+			Name:        item.GetName(),
+			Description: item.GetDescription(),
+			Price:       json.Number(item.GetPrice()),
+			Category:    item.GetCategory(),
+			Images:      item.GetImages(),
+		}
+		// AsTime of a missing timestamp is 1970-01-01, while JSON leaves the
+		// zero time
+		if ts := item.GetCreatedAt(); ts != nil {
+			e.Item.CreatedAt = ts.AsTime()
+		}
+		if item.UpdatedAt != nil {
+			updatedAt := item.GetUpdatedAt().AsTime()
+			e.Item.UpdatedAt = &updatedAt
+		}
+	}
+	return e
+}
+
+// Version orders events of one product for consumers that must not apply an
+// older event over a newer one: the item's updated_at, else the time the
+// event occurred, else fallback (typically the Kafka message timestamp).
+func (e ProductEvent) Version(fallback time.Time) time.Time {
+	switch {
+	case e.Item != nil && e.Item.UpdatedAt != nil:
+		return *e.Item.UpdatedAt
+	case !e.OccurredAt.IsZero():
+		return e.OccurredAt
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,186 @@
+// Package events defines the product event envelope shared by the producers
+// and consumers of the item-events topic.
+//
+// Events are encoded as JSON or Protobuf; the encoding is named by the
+// content-type header. The JSON form is backward compatible with events
+// published before the envelope was versioned: those have no schema_version
+// and are read as SchemaV1.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SchemaV1 is the current envelope version.
+const SchemaV1 = 1
+
+type Operation int
+
+const (
+	OpDelete Operation = 1
+	OpChange Operation = 2
+	OpCreate Operation = 3
+)
+
+func (o Operation) String() string {
+	switch o {
+	case OpDelete:
+		return "delete"
+	case OpChange:
+		return "change"
+	case OpCreate:
+		return "create"
+	default:
+		return "unknown"
+	}
+}
+
+func (o Operation) Valid() bool {
+	return o == OpDelete || o == OpChange || o == OpCreate
+}
+
+type ProductEvent struct {
+	SchemaVersion int       `json:"schema_version,omitempty"`
+	EventID       string    `json:"event_id,omitempty"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	Operation     Operation `json:"operation_type"`
+	ItemID        int64     `json:"item_id"`
+	Item          *Item     `json:"item"`
+}
+
+// Item is the product as known to the catalogue. Attributes and variants
+// are not part of it; they change through their own tables.
+type Item struct {
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       json.Number `json:"price"`
+	Category    string      `json:"category"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   *time.Time  `json:"updated_at,omitempty"`
+	Images      []string    `json:"images"`
+}
+
+var ErrInvalid = errors.New("invalid product event")
+
+// naiveLayout is how Python's datetime.isoformat() renders a datetime without
+// a time zone. Such timestamps are read as UTC.
+const naiveLayout = "2006-01-02T15:04:05.999999999"
+
+func (e *ProductEvent) UnmarshalJSON(data []byte) error {
+	type event ProductEvent
+	v := struct {
+		*event
+		OccurredAt *string `json:"occurred_at"`
+	}{event: (*event)(e)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	var err error
+	e.OccurredAt, err = parseTime(v.OccurredAt)
+	return err
+}
+
+func (i *Item) UnmarshalJSON(data []byte) error {
+	type item Item
+	v := struct {
+		*item
+		CreatedAt *string `json:"created_at"`
+		UpdatedAt *string `json:"updated_at"`
+	}{item: (*item)(i)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	var err error
+	if i.CreatedAt, err = parseTime(v.CreatedAt); err != nil {
+		return err
+	}
+	i.UpdatedAt = nil
+	if v.UpdatedAt != nil {
+		updatedAt, err := parseTime(v.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		i.UpdatedAt = &updatedAt
+	}
+	return nil
+}
+
+// parseTime reads an RFC 3339 or naive ISO 8601 timestamp. A missing one is
+// the zero time.
+func parseTime(s *string) (time.Time, error) {
+	if s == nil || *s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, *s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(naiveLayout, *s, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", *s)
+	}
+	return t, nil
+}
+
+// New returns a current-schema event with a fresh ID. item must be nil for
+// OpDelete.
+func New(op Operation, itemID int64, item *Item) ProductEvent {
+	return ProductEvent{
+		SchemaVersion: SchemaV1,
+		EventID:       newID(),
+		OccurredAt:    time.Now().UTC(),
+		Operation:     op,
+		ItemID:        itemID,
+		Item:          item,
+	}
+}
+
+// Ref is the part of an event needed to drop a cached product.
+type Ref struct {
+	Operation Operation
+	ItemID    int64
+}
+
+// Validate checks the invariants consumers rely on. Errors wrap ErrInvalid.
+func (e ProductEvent) Validate() error {
+	if e.SchemaVersion > SchemaV1 {
+		return fmt.Errorf("%w: unsupported schema version %d", ErrInvalid, e.SchemaVersion)
+	}
+	if !e.Operation.Valid() {
+		return fmt.Errorf("%w: unknown operation type %d", ErrInvalid, e.Operation)
+	}
+	if e.ItemID <= 0 {
+		return fmt.Errorf("%w: item_id must be positive, got %d", ErrInvalid, e.ItemID)
+	}
+
+	if e.Operation == OpDelete {
+		return nil
+	}
+	if e.Item == nil {
+		return fmt.Errorf("%w: %s event has no item", ErrInvalid, e.Operation)
+	}
+	if e.Item.ID != e.ItemID {
+		return fmt.Errorf("%w: item.id %d does not match item_id %d", ErrInvalid, e.Item.ID, e.ItemID)
+	}
+	if _, err := e.Item.Price.Float64(); err != nil {
+		return fmt.Errorf("%w: price %q is not a number", ErrInvalid, e.Item.Price)
+	}
+	return nil
+}
+
+// newID returns a random RFC 4122 version 4 UUID.
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,154 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	eventsv1 "github.com/Riter/E-Shop/common/gen/go/events/v1"
+)
+
+func TestUnmarshalLegacyJSON(t *testing.T) {
+	raw := `{"operation_type": 2, "item_id": 42, "item": {"id": 42, "name": "Sony WH-1000XM5",
+		"description": "", "price": 399.99, "category": "audio", "created_at": "2025-05-01T12:00:00Z", "images": []}}`
+
+	e, err := Unmarshal([]byte(raw), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Operation != OpChange || e.ItemID != 42 || e.Item.Price.String() != "399.99" {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
+
+// pythonEvent is what manage_item_crud publishes for a created item:
+// json.dumps with datetime.isoformat(), so created_at has no time zone when
+// the client sent none.
+const pythonEvent = `{"schema_version": 1, "event_id": "3f1c9a62-6a55-4c1e-9a7e-2b8f1d7c4e10", "occurred_at": "2025-05-01T12:00:03.512034+00:00", "operation_type": 3, "item_id": 7, "item": {"id": 7, "name": "Kindle Paperwhite", "description": "6.8\" e-ink", "price": 129.0, "category": "electronics", "created_at": "2025-05-01T12:00:00.250000", "images": ["https://minio/products/7/1.jpg"]}}`
+
+func TestUnmarshalPythonProducer(t *testing.T) {
+	e, err := Unmarshal([]byte(pythonEvent), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Date(2025, 5, 1, 12, 0, 0, 250000000, time.UTC); !e.Item.CreatedAt.Equal(want) {
+		t.Errorf("expected naive created_at read as UTC %s, got %s", want, e.Item.CreatedAt)
+	}
+	if want := time.Date(2025, 5, 1, 12, 0, 3, 512034000, time.UTC); !e.OccurredAt.Equal(want) {
+		t.Errorf("expected occurred_at %s, got %s", want, e.OccurredAt)
+	}
+	if e.Operation != OpCreate || e.ItemID != 7 || e.Item.Price.String() != "129.0" || e.Item.UpdatedAt != nil {
+		t.Errorf("unexpected event %+v, item %+v", e, e.Item)
+	}
+}
+
+func TestUnmarshalRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    Ref
+		wantErr bool
+	}{
+		{name: "python producer", raw: pythonEvent, want: Ref{Operation: OpCreate, ItemID: 7}},
+		{name: "mismatched item id", raw: `{"operation_type": 2, "item_id": 7, "item": {"id": 8, "price": 1}}`, want: Ref{Operation: OpChange, ItemID: 7}},
+		{name: "bad timestamp", raw: `{"operation_type": 2, "item_id": 7, "item": {"id": 7, "created_at": "yesterday"}}`, want: Ref{Operation: OpChange, ItemID: 7}},
+		{name: "delete", raw: `{"operation_type": 1, "item_id": 7, "item": null}`, want: Ref{Operation: OpDelete, ItemID: 7}},
+		{name: "unknown operation", raw: `{"operation_type": 9, "item_id": 7}`, wantErr: true},
+		{name: "missing item id", raw: `{"operation_type": 1}`, wantErr: true},
+		{name: "not json", raw: `item 7`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnmarshalRef([]byte(tt.raw), "")
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalid) {
+					t.Errorf("expected ErrInvalid, got %v", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("expected %+v, got %+v, %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	updatedAt := time.Date(2025, 5, 2, 9, 30, 0, 0, time.UTC)
+	want := New(OpCreate, 7, &Item{
+		ID:        7,
+		Name:      "Kindle",
+		Price:     "129.00",
+		CreatedAt: time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC),
+		UpdatedAt: &updatedAt,
+		Images:    []string{"https://minio/products/7/1.jpg"},
+	})
+
+	data, err := Marshal(want, Protobuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Unmarshal(data, string(Protobuf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.EventID != want.EventID || got.SchemaVersion != SchemaV1 || !got.OccurredAt.Equal(want.OccurredAt) {
+		t.Errorf("envelope changed: got %+v, want %+v", got, want)
+	}
+	if got.Item.Price != "129.00" || !got.Item.UpdatedAt.Equal(updatedAt) || len(got.Item.Images) != 1 {
+		t.Errorf("item changed: got %+v", got.Item)
+	}
+	if v := got.Version(time.Time{}); !v.Equal(updatedAt) {
+		t.Errorf("expected version %s, got %s", updatedAt, v)
+	}
+}
+
+func TestCodecsAgreeOnMissingCreatedAt(t *testing.T) {
+	event := New(OpChange, 7, &Item{ID: 7, Name: "Kindle", Price: "129.00"})
+
+	for _, encoding := range []Encoding{JSON, Protobuf} {
+		t.Run(string(encoding), func(t *testing.T) {
+			data, err := Marshal(event, encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Unmarshal(data, string(encoding))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Item.CreatedAt.IsZero() {
+				t.Errorf("expected zero created_at, got %s", got.Item.CreatedAt)
+			}
+		})
+	}
+
+	// a producer that doesn't set the field
+	got := fromProto(&eventsv1.ProductEvent{ItemId: 7, Item: &eventsv1.Item{Id: 7}})
+	if !got.Item.CreatedAt.IsZero() {
+		t.Errorf("expected zero created_at without the field, got %s", got.Item.CreatedAt)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	item := &Item{ID: 1, Price: "10"}
+	cases := map[string]ProductEvent{
+		"future schema":       {SchemaVersion: SchemaV1 + 1, Operation: OpDelete, ItemID: 1},
+		"unknown operation":   {Operation: 4, ItemID: 1},
+		"missing item id":     {Operation: OpDelete},
+		"create without item": {Operation: OpCreate, ItemID: 1},
+		"mismatched id":       {Operation: OpChange, ItemID: 2, Item: item},
+		"bad price":           {Operation: OpChange, ItemID: 1, Item: &Item{ID: 1, Price: "ten"}},
+	}
+	for name, e := range cases {
+		if err := e.Validate(); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: expected ErrInvalid, got %v", name, err)
+		}
+	}
+
+	if err := (ProductEvent{Operation: OpChange, ItemID: 1, Item: item}).Validate(); err != nil {
+		t.Errorf("valid event rejected: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+// Package httperr is the JSON error model shared by the Go HTTP services:
+//
+//	{"error": {"code": "invalid_sku", "message": "...", "request_id": "...", "details": {...}}}
+//
+// Handlers return *Error values for expected failures and let Write turn any
+// other error into a generic 500, so driver messages never reach clients.
+package httperr
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+type Error struct {
+	Status    int    `json:"-"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Details   any    `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// WithDetails returns a copy of e carrying details, e.g. the offending values.
+func (e *Error) WithDetails(details any) *Error {
+	cp := *e
+	cp.Details = details
+	return &cp
+}
+
+func BadRequest(code, message string) *Error {
+	return New(http.StatusBadRequest, code, message)
+}
+
+func NotFound(code, message string) *Error {
+	return New(http.StatusNotFound, code, message)
+}
+
+func Internal() *Error {
+	return New(http.StatusInternalServerError, "internal_error", "internal server error")
+}
+
+type envelope struct {
+	Error *Error `json:"error"`
+}
+
+// Write responds with err in the shared envelope. Errors that are not *Error
+// are logged and reported as internal_error without their text.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		log.Printf("request %s failed: %v", RequestID(r.Context()), err)
+		apiErr = Internal()
+	}
+
+	resp := *apiErr
+	resp.RequestID = RequestID(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	json.NewEncoder(w).Encode(envelope{Error: &resp})
+}
@@ -9,6 +9,7 @@ import (
 	"online-shop/internal/db"
 	"online-shop/internal/elasticsearch"
 	"online-shop/internal/kafka"
+	"online-shop/internal/ratings"
 	"online-shop/internal/repository"
 	"online-shop/internal/services"
 	"os"
@@ -25,6 +26,7 @@ import (
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 
+	"github.com/Riter/E-Shop/common/httperr"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -78,10 +80,35 @@ func main() {
 
 	
 	productRepo := repository.NewProductRepo(db.PsqlDB, db.MinioClient)
-	elasticManager := services.NewElasticManager(productRepo, elasticClient)
+	ratingsClient := ratings.NewClient(config.LoadCommentsConfig())
+	popularityRepo := repository.NewPopularityRepo(db.PsqlDB)
+	merch := services.NewMerchandiser(repository.NewMerchRepo(db.PsqlDB), elasticClient, config.LoadMerchConfig())
+	if err := merch.Load(); err != nil {
+		log.Fatalf("ошибка при загрузке настроек поиска: %v", err)
+	}
+	searchLogger := services.NewSearchLogger(elasticClient, config.LoadAnalyticsConfig())
+	elasticManager := services.NewElasticManager(elasticClient, merch, searchLogger)
+	syncer := services.NewSyncer(productRepo, elasticClient, ratingsClient, popularityRepo, config.LoadSyncConfig())
 
 	
-	if err := elasticManager.SyncProductsToElasticSearch(); err != nil {
+	reindexer := services.NewReindexer(productRepo, elasticClient, ratingsClient, popularityRepo)
+
+	reindex, err := elasticClient.EnsureIndex()
+	if err != nil {
+		log.Fatalf("ошибка при подготовке индекса: %v", err)
+	}
+	if err := merch.SyncSynonyms(); err != nil {
+		log.Printf("Ошибка при применении синонимов: %v", err)
+	}
+	if err := elasticClient.EnsureSearchLogs(); err != nil {
+		log.Printf("Ошибка при подготовке журнала поиска: %v", err)
+	}
+	if reindex {
+		log.Println("Маппинг индекса products устарел, запускаем переиндексацию...")
+		if err := reindexer.Run(); err != nil {
+			log.Printf("Ошибка переиндексации: %v", err)
+		}
+	} else if err := syncer.Run(); err != nil {
 		log.Printf("Ошибка при начальной синхронизации с PostgreSQL: %v", err)
 	}
 
@@ -91,30 +118,65 @@ func main() {
 		kafkaConfig.Brokers,
 		kafkaConfig.GroupID,
 		kafkaConfig.Topic,
+		kafkaConfig.BatchSize,
+		kafkaConfig.BatchTimeout,
 		elasticClient,
+		ratingsClient,
+		popularityRepo,
 	)
 	if err != nil {
 		log.Fatalf("ошибка при создании Kafka consumer: %v", err)
 	}
+	signalsConsumer, err := kafka.NewSignalsConsumer(
+		kafkaConfig.Brokers,
+		kafkaConfig.SignalsGroupID,
+		kafkaConfig.CommentsTopic,
+		kafkaConfig.ViewsTopic,
+		kafkaConfig.BatchSize,
+		kafkaConfig.BatchTimeout,
+		elasticClient,
+		ratingsClient,
+		popularityRepo,
+	)
+	if err != nil {
+		log.Fatalf("ошибка при создании Kafka consumer сигналов ранжирования: %v", err)
+	}
 
 	
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	go merch.Watch(ctx)
+	go searchLogger.Run()
+	go syncer.Watch(ctx)
+
 	go func() {
 		if err := consumer.Start(ctx); err != nil {
 			log.Printf("ошибка в Kafka consumer: %v", err)
 		}
 	}()
+	go func() {
+		if err := signalsConsumer.Start(ctx); err != nil {
+			log.Printf("ошибка в Kafka consumer сигналов ранжирования: %v", err)
+		}
+	}()
 
 	
 	r := chi.NewRouter()
 	r.Use(otelhttp.NewMiddleware("elastic-search-service"))
+	r.Use(httperr.RequestIDMiddleware)
 
 	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("pong"))
 	})
 	r.Get("/search", elasticManager.ServeHTTP)
+	r.Get("/search/suggest", elasticManager.Suggest)
+	r.Post("/search/click", searchLogger.Click)
+	r.Post("/admin/reindex", reindexer.ServeHTTP)
+	r.Route("/admin/search", func(r chi.Router) {
+		merch.Routes(r)
+		r.Route("/analytics", searchLogger.Routes)
+	})
 
 	
 	srv := &http.Server{
@@ -145,6 +207,7 @@ func main() {
 	if err := srv.Shutdown(context.Background()); err != nil {
 		log.Printf("ошибка при graceful shutdown сервера: %v", err)
 	}
+	searchLogger.Close()
 
 	log.Println("Сервер успешно остановлен")
 }
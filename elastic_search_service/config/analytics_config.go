@@ -0,0 +1,30 @@
+package config
+
+import (
+	"log"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// AnalyticsConfig - настройки журнала поиска. События копятся в очереди на
+// QueueSize записей и отправляются пачками; при переполнении очереди новые
+// события отбрасываются, чтобы журнал не замедлял поиск.
+type AnalyticsConfig struct {
+	QueueSize     int
+	FlushSize     int
+	FlushInterval time.Duration
+}
+
+func LoadAnalyticsConfig() *AnalyticsConfig {
+	err := godotenv.Load("../environment/elastic.env")
+	if err != nil {
+		log.Fatal("Ошибка при загрузке конфига elastic")
+	}
+
+	return &AnalyticsConfig{
+		QueueSize:     getEnvInt("SEARCH_LOG_QUEUE_SIZE", 10000),
+		FlushSize:     getEnvInt("SEARCH_LOG_FLUSH_SIZE", 500),
+		FlushInterval: getEnvDuration("SEARCH_LOG_FLUSH_INTERVAL", 5*time.Second),
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// CommentsConfig описывает сервис комментариев, из которого берутся
+// рейтинги товаров для индекса.
+type CommentsConfig struct {
+	URL       string
+	Timeout   time.Duration
+	BatchSize int
+}
+
+func LoadCommentsConfig() *CommentsConfig {
+	err := godotenv.Load("../environment/comments.env")
+	if err != nil {
+		log.Fatalf("ошибка при загрузке comments.env файла: %v", err)
+	}
+
+	return &CommentsConfig{
+		URL:       os.Getenv("COMMENTS_SERVICE_URL"),
+		Timeout:   getEnvDuration("COMMENTS_TIMEOUT", 5*time.Second),
+		BatchSize: getEnvInt("COMMENTS_BATCH_SIZE", 500),
+	}
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -13,6 +16,29 @@ type EsConfig struct {
 	ElasticPassword string
 	ElasticCluster  string
 	ESJavaOpts      string
+
+	BulkFlushSize    int
+	BulkWorkers      int
+	BulkMaxRetries   int
+	BulkRetryBackoff time.Duration
+
+	Ranking RankingConfig
+}
+
+// RankingConfig - настройки function_score для сортировки по релевантности.
+// Популярность учитывается через field_value_factor с модификатором
+// PopularityModifier, рейтинг - через gauss-затухание от RatingOrigin.
+// Нулевой множитель или вес отключает соответствующую функцию.
+type RankingConfig struct {
+	PopularityFactor   float64
+	PopularityModifier string
+	RatingWeight       float64
+	RatingOrigin       float64
+	RatingScale        float64
+	RatingDecay        float64
+	MinReviews         int
+	BoostMode          string
+	MaxBoost           float64
 }
 
 
@@ -28,5 +54,73 @@ func LoadEsConfig() *EsConfig {
 		ElasticPassword: os.Getenv("ELASTIC_PASSWORD"),
 		ElasticCluster:  os.Getenv("ELASTIC_CLUSTER_NAME"),
 		ESJavaOpts:      os.Getenv("ES_JAVA_OPTS"),
+
+		BulkFlushSize:    getEnvInt("ES_BULK_FLUSH_SIZE", 500),
+		BulkWorkers:      getEnvInt("ES_BULK_WORKERS", 2),
+		BulkMaxRetries:   getEnvInt("ES_BULK_MAX_RETRIES", 3),
+		BulkRetryBackoff: getEnvDuration("ES_BULK_RETRY_BACKOFF", 500*time.Millisecond),
+
+		Ranking: RankingConfig{
+			PopularityFactor:   getEnvFloat("SEARCH_RANK_POPULARITY_FACTOR", 1),
+			PopularityModifier: getEnvOneOf("SEARCH_RANK_POPULARITY_MODIFIER", "log2p", "none", "log1p", "log2p", "ln1p", "ln2p", "sqrt"),
+			RatingWeight:       getEnvFloat("SEARCH_RANK_RATING_WEIGHT", 1),
+			RatingOrigin:       getEnvFloat("SEARCH_RANK_RATING_ORIGIN", 5),
+			RatingScale:        getEnvFloat("SEARCH_RANK_RATING_SCALE", 1.5),
+			RatingDecay:        getEnvFloat("SEARCH_RANK_RATING_DECAY", 0.5),
+			MinReviews:         getEnvInt("SEARCH_RANK_MIN_REVIEWS", 1),
+			BoostMode:          getEnvOneOf("SEARCH_RANK_BOOST_MODE", "multiply", "multiply", "sum", "avg", "max", "min", "replace"),
+			MaxBoost:           getEnvFloat("SEARCH_RANK_MAX_BOOST", 10),
+		},
+	}
+}
+
+func getEnv(key string, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func getEnvInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("ошибка при считывании %s: %v", key, err)
+	}
+	return n
+}
+
+func getEnvFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Fatalf("ошибка при считывании %s: %v", key, err)
+	}
+	return f
+}
+
+func getEnvOneOf(key string, def string, allowed ...string) string {
+	v := getEnv(key, def)
+	if !slices.Contains(allowed, v) {
+		log.Fatalf("ошибка при считывании %s: %q, допустимы %v", key, v, allowed)
+	}
+	return v
+}
+
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("ошибка при считывании %s: %v", key, err)
 	}
+	return d
 }
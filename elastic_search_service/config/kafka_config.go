@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +13,17 @@ type KafkaConfig struct {
 	Brokers []string
 	GroupID string
 	Topic   string
+
+	// Сообщения применяются к Elasticsearch пачками до BatchSize штук;
+	// неполная пачка отправляется через BatchTimeout.
+	BatchSize    int
+	BatchTimeout time.Duration
+
+	// Сигналы ранжирования читаются отдельной группой: события комментариев
+	// обновляют рейтинг товара, просмотры - популярность.
+	SignalsGroupID string
+	CommentsTopic  string
+	ViewsTopic     string
 }
 
 func LoadKafkaConfig() *KafkaConfig {
@@ -29,5 +41,12 @@ func LoadKafkaConfig() *KafkaConfig {
 		Brokers: brokers,
 		GroupID: os.Getenv("KAFKA_GROUP_ID"),
 		Topic:   os.Getenv("KAFKA_TOPIC"),
+
+		BatchSize:    getEnvInt("KAFKA_BATCH_SIZE", 100),
+		BatchTimeout: getEnvDuration("KAFKA_BATCH_TIMEOUT", 200*time.Millisecond),
+
+		SignalsGroupID: getEnv("KAFKA_SIGNALS_GROUP_ID", "search-signals-group"),
+		CommentsTopic:  getEnv("KAFKA_COMMENTS_TOPIC", "product-comments"),
+		ViewsTopic:     getEnv("KAFKA_VIEWS_TOPIC", "product-views"),
 	}
 }
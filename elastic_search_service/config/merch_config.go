@@ -0,0 +1,34 @@
+package config
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// MerchConfig - настройки мерчендайзинга поиска. SynonymsFile должен
+// указывать на analysis/synonyms.txt в каталоге конфигурации Elasticsearch
+// (в docker-compose это общий каталог synonyms).
+type MerchConfig struct {
+	SynonymsFile    string
+	RefreshInterval time.Duration
+}
+
+func LoadMerchConfig() *MerchConfig {
+	err := godotenv.Load("../environment/elastic.env")
+	if err != nil {
+		log.Fatal("Ошибка при загрузке конфига elastic")
+	}
+
+	synonymsFile := os.Getenv("ES_SYNONYMS_FILE")
+	if synonymsFile == "" {
+		synonymsFile = "../synonyms/synonyms.txt"
+	}
+
+	return &MerchConfig{
+		SynonymsFile:    synonymsFile,
+		RefreshInterval: getEnvDuration("SEARCH_MERCH_REFRESH", 30*time.Second),
+	}
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"log"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// SyncConfig - настройки инкрементальной синхронизации с PostgreSQL.
+// Каждый проход перечитывает изменения за последние Lag до сохранённой
+// отметки: транзакция, закоммиченная позже, могла записать более раннее
+// время, и без перекрытия такое изменение было бы пропущено.
+// Документы, отклонённые Elasticsearch, повторяются до MaxRetries раз.
+// Раз в SignalsInterval рейтинги и популярность всех товаров перечитываются
+// целиком; 0 отключает обновление.
+type SyncConfig struct {
+	Interval        time.Duration
+	PageSize        int
+	Lag             time.Duration
+	TombstoneTTL    time.Duration
+	MaxRetries      int
+	SignalsInterval time.Duration
+}
+
+func LoadSyncConfig() *SyncConfig {
+	err := godotenv.Load("../environment/elastic.env")
+	if err != nil {
+		log.Fatal("Ошибка при загрузке конфига elastic")
+	}
+
+	return &SyncConfig{
+		Interval:        getEnvDuration("SEARCH_SYNC_INTERVAL", time.Minute),
+		PageSize:        getEnvInt("SEARCH_SYNC_PAGE_SIZE", 500),
+		Lag:             getEnvDuration("SEARCH_SYNC_LAG", 30*time.Second),
+		TombstoneTTL:    getEnvDuration("SEARCH_SYNC_TOMBSTONE_TTL", 7*24*time.Hour),
+		MaxRetries:      getEnvInt("SEARCH_SYNC_MAX_RETRIES", 5),
+		SignalsInterval: getEnvDuration("SEARCH_SYNC_SIGNALS_INTERVAL", time.Hour),
+	}
+}
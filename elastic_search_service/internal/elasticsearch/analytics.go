@@ -0,0 +1,237 @@
+package elasticsearch
+
+import (
+	"online-shop/internal/models"
+	"strings"
+	"time"
+)
+
+// Журнал поиска хранится в помесячных индексах search-logs-YYYY.MM: старые
+// месяцы удаляются целиком, без delete_by_query.
+const (
+	searchLogsTemplateName = "search-logs"
+	searchLogsPrefix       = "search-logs-"
+)
+
+const searchLogsTemplate = `{
+  "index_patterns": ["search-logs-*"],
+  "template": {
+    "settings": {
+      "number_of_shards": 1,
+      "analysis": {
+        "normalizer": {
+          "lowercase": {"type": "custom", "filter": ["lowercase"]}
+        }
+      }
+    },
+    "mappings": {
+      "dynamic": false,
+      "properties": {
+        "type": {"type": "keyword"},
+        "search_id": {"type": "keyword"},
+        "request_id": {"type": "keyword"},
+        "user_id": {"type": "keyword"},
+        "query": {"type": "keyword", "normalizer": "lowercase"},
+        "@timestamp": {"type": "date"},
+        "filters": {
+          "properties": {
+            "categories": {"type": "keyword"},
+            "min_price": {"type": "double"},
+            "max_price": {"type": "double"},
+            "min_rating": {"type": "double"}
+          }
+        },
+        "sort": {"type": "keyword"},
+        "first_page": {"type": "boolean"},
+        "hits": {"type": "long"},
+        "took_ms": {"type": "long"},
+        "latency_ms": {"type": "long"},
+        "product_id": {"type": "integer"},
+        "position": {"type": "integer"}
+      }
+    }
+  }
+}`
+
+// EnsureSearchLogs создаёт шаблон индексов журнала поиска.
+func (es *ESClient) EnsureSearchLogs() error {
+	res, err := es.Client.Indices.PutIndexTemplate(searchLogsTemplateName, strings.NewReader(searchLogsTemplate))
+	return checkResponse(res, err, "ошибка при создании шаблона журнала поиска")
+}
+
+// SearchLogIndex возвращает индекс журнала, в который попадает событие.
+func SearchLogIndex(t time.Time) string {
+	return searchLogsPrefix + t.UTC().Format("2006.01")
+}
+
+// AnalyticsRange - период и число запросов в отчёте.
+type AnalyticsRange struct {
+	From time.Time
+	To   time.Time
+	Size int
+}
+
+// QueryStats - строка отчёта по одному запросу. Поля, которые отчёт не
+// считает, остаются нулевыми.
+type QueryStats struct {
+	Query    string   `json:"query"`
+	Searches int64    `json:"searches"`
+	AvgHits  *float64 `json:"avg_hits,omitempty"`
+	Clicks   *int64   `json:"clicks,omitempty"`
+	CTR      *float64 `json:"ctr,omitempty"`
+}
+
+// TopQueries возвращает самые частые запросы за период. Листание страниц
+// одного поиска не учитывается.
+func (es *ESClient) TopQueries(r AnalyticsRange) ([]QueryStats, error) {
+	aggs := map[string]any{
+		"queries": map[string]any{
+			"terms": map[string]any{"field": "query", "size": r.Size},
+			"aggs": map[string]any{
+				"avg_hits": map[string]any{"avg": map[string]any{"field": "hits"}},
+			},
+		},
+	}
+
+	var response struct {
+		Aggregations struct {
+			Queries struct {
+				Buckets []struct {
+					Key      string `json:"key"`
+					DocCount int64  `json:"doc_count"`
+					AvgHits  struct {
+						Value float64 `json:"value"`
+					} `json:"avg_hits"`
+				} `json:"buckets"`
+			} `json:"queries"`
+		} `json:"aggregations"`
+	}
+	if err := es.searchLogs(searchEventsFilter(r), aggs, &response); err != nil {
+		return nil, err
+	}
+
+	stats := make([]QueryStats, 0, len(response.Aggregations.Queries.Buckets))
+	for _, b := range response.Aggregations.Queries.Buckets {
+		avgHits := b.AvgHits.Value
+		stats = append(stats, QueryStats{Query: b.Key, Searches: b.DocCount, AvgHits: &avgHits})
+	}
+	return stats, nil
+}
+
+// ZeroResultQueries возвращает самые частые запросы, по которым ничего не
+// нашлось.
+func (es *ESClient) ZeroResultQueries(r AnalyticsRange) ([]QueryStats, error) {
+	filter := append(searchEventsFilter(r), map[string]any{"term": map[string]any{"hits": 0}})
+	aggs := map[string]any{
+		"queries": map[string]any{
+			"terms": map[string]any{"field": "query", "size": r.Size},
+		},
+	}
+
+	var response struct {
+		Aggregations struct {
+			Queries struct {
+				Buckets []struct {
+					Key      string `json:"key"`
+					DocCount int64  `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"queries"`
+		} `json:"aggregations"`
+	}
+	if err := es.searchLogs(filter, aggs, &response); err != nil {
+		return nil, err
+	}
+
+	stats := make([]QueryStats, 0, len(response.Aggregations.Queries.Buckets))
+	for _, b := range response.Aggregations.Queries.Buckets {
+		stats = append(stats, QueryStats{Query: b.Key, Searches: b.DocCount})
+	}
+	return stats, nil
+}
+
+// QueryCTR возвращает долю поисков с хотя бы одним кликом для самых частых
+// запросов. Поиски с кликом считаются через cardinality по search_id, поэтому
+// при большом числе кликов значение приблизительное.
+func (es *ESClient) QueryCTR(r AnalyticsRange) ([]QueryStats, error) {
+	filter := []map[string]any{timeRange(r)}
+	aggs := map[string]any{
+		"queries": map[string]any{
+			"terms": map[string]any{
+				"field": "query",
+				"size":  r.Size,
+				"order": map[string]string{"searches": "desc"},
+			},
+			"aggs": map[string]any{
+				"searches": map[string]any{"filter": map[string]any{"bool": map[string]any{"filter": searchEventsFilter(r)}}},
+				"clicks": map[string]any{
+					"filter": map[string]any{"term": map[string]any{"type": models.SearchEventClick}},
+					"aggs": map[string]any{
+						"searches": map[string]any{"cardinality": map[string]any{"field": "search_id"}},
+					},
+				},
+			},
+		},
+	}
+
+	var response struct {
+		Aggregations struct {
+			Queries struct {
+				Buckets []struct {
+					Key      string `json:"key"`
+					Searches struct {
+						DocCount int64 `json:"doc_count"`
+					} `json:"searches"`
+					Clicks struct {
+						DocCount int64 `json:"doc_count"`
+						Searches struct {
+							Value int64 `json:"value"`
+						} `json:"searches"`
+					} `json:"clicks"`
+				} `json:"buckets"`
+			} `json:"queries"`
+		} `json:"aggregations"`
+	}
+	if err := es.searchLogs(filter, aggs, &response); err != nil {
+		return nil, err
+	}
+
+	stats := make([]QueryStats, 0, len(response.Aggregations.Queries.Buckets))
+	for _, b := range response.Aggregations.Queries.Buckets {
+		if b.Searches.DocCount == 0 {
+			continue
+		}
+		clicks := b.Clicks.DocCount
+		ctr := min(float64(b.Clicks.Searches.Value)/float64(b.Searches.DocCount), 1)
+		stats = append(stats, QueryStats{Query: b.Key, Searches: b.Searches.DocCount, Clicks: &clicks, CTR: &ctr})
+	}
+	return stats, nil
+}
+
+// searchEventsFilter отбирает поиски за период, кроме запросов следующих
+// страниц.
+func searchEventsFilter(r AnalyticsRange) []map[string]any {
+	return []map[string]any{
+		timeRange(r),
+		{"term": map[string]any{"type": models.SearchEventSearch}},
+		{"term": map[string]any{"first_page": true}},
+	}
+}
+
+func timeRange(r AnalyticsRange) map[string]any {
+	return map[string]any{
+		"range": map[string]any{
+			"@timestamp": map[string]any{"gte": r.From.UTC(), "lt": r.To.UTC()},
+		},
+	}
+}
+
+// searchLogs выполняет агрегацию по журналу поиска. Если журнал ещё пуст,
+// шаблон search-logs-* не совпадает ни с одним индексом и ответ пустой.
+func (es *ESClient) searchLogs(filter []map[string]any, aggs map[string]any, out any) error {
+	searchBody := map[string]any{
+		"size":  0,
+		"query": map[string]any{"bool": map[string]any{"filter": filter}},
+		"aggs":  aggs,
+	}
+	return es.searchIndex(searchLogsPrefix+"*", searchBody, out)
+}
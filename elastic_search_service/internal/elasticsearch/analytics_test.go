@@ -0,0 +1,59 @@
+package elasticsearch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchEventsFilter(t *testing.T) {
+	r := AnalyticsRange{
+		From: time.Date(2025, 5, 1, 3, 0, 0, 0, time.FixedZone("MSK", 3*60*60)),
+		To:   time.Date(2025, 5, 8, 0, 0, 0, 0, time.UTC),
+	}
+
+	// границы переводятся в UTC, правая не включается
+	assertJSON(t, searchEventsFilter(r), `[
+		{"range": {"@timestamp": {"gte": "2025-05-01T00:00:00Z", "lt": "2025-05-08T00:00:00Z"}}},
+		{"term": {"type": "search"}},
+		{"term": {"first_page": true}}
+	]`)
+}
+
+func TestSearchLogIndex(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Date(2025, 5, 31, 23, 59, 0, 0, time.UTC), "search-logs-2025.05"},
+		{time.Date(2025, 6, 1, 1, 0, 0, 0, time.FixedZone("MSK", 3*60*60)), "search-logs-2025.05"},
+		{time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC), "search-logs-2025.12"},
+	}
+
+	for _, tt := range tests {
+		if got := SearchLogIndex(tt.t); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.t, tt.want, got)
+		}
+	}
+}
+
+func TestAnalyticsReportsRequestTimeRange(t *testing.T) {
+	es, fake := newFakeES(t, map[string]string{"/search-logs-*/_search": `{"aggregations": {"queries": {"buckets": [
+		{"key": "iphone", "doc_count": 4, "searches": {"doc_count": 4}, "clicks": {"doc_count": 3, "searches": {"value": 2}}},
+		{"key": "only clicks", "doc_count": 1, "searches": {"doc_count": 0}, "clicks": {"doc_count": 1, "searches": {"value": 1}}}
+	]}}}`})
+	r := AnalyticsRange{From: time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 5, 8, 0, 0, 0, 0, time.UTC), Size: 10}
+
+	stats, err := es.QueryCTR(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Query != "iphone" || *stats[0].CTR != 0.5 || *stats[0].Clicks != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// клики считаются за тот же период, что и поиски
+	body := fake.body("/search-logs-*/_search")
+	assertJSON(t, body["query"], `{"bool": {"filter": [
+		{"range": {"@timestamp": {"gte": "2025-05-01T00:00:00Z", "lt": "2025-05-08T00:00:00Z"}}}
+	]}}`)
+}
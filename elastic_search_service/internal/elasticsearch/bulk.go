@@ -0,0 +1,296 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+const (
+	BulkIndex  = "index"
+	BulkCreate = "create"
+	BulkDelete = "delete"
+	BulkUpdate = "update"
+)
+
+// BulkConfig задаёт, сколько операций уходит в одном bulk-запросе и сколько
+// запросов к Elasticsearch выполняется параллельно. Индексатор живёт один
+// вызов ESClient.BulkTo, поэтому отправки по таймеру нет: остаток пачки
+// отправляется при Close.
+type BulkConfig struct {
+	FlushSize    int
+	Workers      int
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+// BulkItem - одна операция bulk-запроса. BulkCreate не перезаписывает уже
+// существующий документ. BulkUpdate меняет только поля из Doc, если документ
+// есть в индексе. Для BulkDelete Doc не нужен.
+type BulkItem struct {
+	Action string
+	ID     string
+	Doc    any
+}
+
+// ItemError описывает документ, который Elasticsearch не принял.
+type ItemError struct {
+	Action string
+	ID     string
+	Status int
+	Type   string
+	Reason string
+}
+
+func (e ItemError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s: %s", e.Action, e.ID, e.Status, e.Type, e.Reason)
+}
+
+// BulkError собирает ошибки по отдельным документам.
+type BulkError struct {
+	Items []ItemError
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("не удалось обработать %d документов, первая ошибка: %v", len(e.Items), e.Items[0])
+}
+
+type bulkOp struct {
+	item BulkItem
+	meta []byte
+	doc  []byte
+}
+
+// BulkIndexer накапливает операции и отправляет их через Bulk API. Операции
+// распределяются по воркерам по хешу ID, поэтому изменения одного документа
+// применяются в том порядке, в котором были добавлены.
+type BulkIndexer struct {
+	client *elasticsearch.Client
+	index  string
+	cfg    BulkConfig
+
+	queues []chan bulkOp
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	failed []ItemError
+	reqErr error
+}
+
+func NewBulkIndexer(client *elasticsearch.Client, index string, cfg BulkConfig) *BulkIndexer {
+	cfg.Workers = max(cfg.Workers, 1)
+	cfg.FlushSize = max(cfg.FlushSize, 1)
+
+	b := &BulkIndexer{
+		client: client,
+		index:  index,
+		cfg:    cfg,
+		queues: make([]chan bulkOp, cfg.Workers),
+	}
+	for i := range b.queues {
+		b.queues[i] = make(chan bulkOp, cfg.FlushSize)
+		b.wg.Add(1)
+		go b.worker(b.queues[i])
+	}
+	return b
+}
+
+// Add ставит операцию в очередь. После Close вызывать Add нельзя.
+func (b *BulkIndexer) Add(item BulkItem) error {
+	meta, err := json.Marshal(map[string]any{item.Action: map[string]string{"_index": b.index, "_id": item.ID}})
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации bulk-операции: %w", err)
+	}
+
+	op := bulkOp{item: item, meta: meta}
+	switch item.Action {
+	case BulkIndex, BulkCreate:
+		if op.doc, err = json.Marshal(item.Doc); err != nil {
+			return fmt.Errorf("ошибка сериализации документа %s: %w", item.ID, err)
+		}
+	case BulkUpdate:
+		if op.doc, err = json.Marshal(map[string]any{"doc": item.Doc}); err != nil {
+			return fmt.Errorf("ошибка сериализации документа %s: %w", item.ID, err)
+		}
+	case BulkDelete:
+	default:
+		return fmt.Errorf("неизвестная bulk-операция %q", item.Action)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(item.ID))
+	b.queues[h.Sum32()%uint32(len(b.queues))] <- op
+	return nil
+}
+
+// Close отправляет оставшиеся операции, дожидается воркеров и возвращает
+// *BulkError, если какие-то документы не были приняты. Если bulk-запрос не
+// удалось выполнить и после повторов, возвращается ошибка запроса.
+func (b *BulkIndexer) Close() error {
+	for _, q := range b.queues {
+		close(q)
+	}
+	b.wg.Wait()
+
+	if b.reqErr != nil {
+		return fmt.Errorf("bulk-запрос не выполнен: %w", b.reqErr)
+	}
+	if len(b.failed) > 0 {
+		return &BulkError{Items: b.failed}
+	}
+	return nil
+}
+
+func (b *BulkIndexer) worker(queue <-chan bulkOp) {
+	defer b.wg.Done()
+
+	batch := make([]bulkOp, 0, b.cfg.FlushSize)
+	for op := range queue {
+		batch = append(batch, op)
+		if len(batch) >= b.cfg.FlushSize {
+			b.flush(batch)
+			batch = batch[:0]
+		}
+	}
+	b.flush(batch)
+}
+
+// flush отправляет пачку и повторяет операции, отклонённые с 429, пока не
+// закончатся попытки.
+func (b *BulkIndexer) flush(batch []bulkOp) {
+	pending := append([]bulkOp(nil), batch...)
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(b.backoff(attempt))
+		}
+
+		retry, err := b.send(pending)
+		if err != nil {
+			if attempt < b.cfg.MaxRetries {
+				log.Printf("bulk-запрос не выполнен, повтор %d: %v", attempt+1, err)
+				continue
+			}
+			b.failRequest(pending, err)
+			return
+		}
+
+		if len(retry) > 0 && attempt >= b.cfg.MaxRetries {
+			b.fail(retry, http.StatusTooManyRequests, "es_rejected_execution_exception", "превышено число повторов")
+			return
+		}
+		pending = retry
+	}
+}
+
+// send выполняет один bulk-запрос. Ошибка возвращается, если запрос не дошёл
+// или был отклонён целиком с 429 или 5xx; отдельные документы со статусом 429 возвращаются
+// для повтора, остальные ошибки записываются сразу.
+func (b *BulkIndexer) send(ops []bulkOp) ([]bulkOp, error) {
+	var body bytes.Buffer
+	for _, op := range ops {
+		body.Write(op.meta)
+		body.WriteByte('\n')
+		if op.doc != nil {
+			body.Write(op.doc)
+			body.WriteByte('\n')
+		}
+	}
+
+	res, err := b.client.Bulk(&body)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		msg, _ := io.ReadAll(res.Body)
+		err := fmt.Errorf("bulk-запрос отклонён: %s %s", res.Status(), strings.TrimSpace(string(msg)))
+		if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+			return nil, err
+		}
+		// повтор не поможет: запрос некорректен целиком
+		b.fail(ops, res.StatusCode, "request_error", err.Error())
+		return nil, nil
+	}
+
+	var parsed struct {
+		Errors bool                          `json:"errors"`
+		Items  []map[string]bulkResponseItem `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования bulk-ответа: %w", err)
+	}
+	if !parsed.Errors {
+		return nil, nil
+	}
+	if len(parsed.Items) != len(ops) {
+		return nil, errors.New("количество элементов в bulk-ответе не совпадает с запросом")
+	}
+
+	var retry []bulkOp
+	for i, item := range parsed.Items {
+		res := item[ops[i].item.Action]
+		switch {
+		case res.Status < 300:
+		case res.Status == http.StatusNotFound && (ops[i].item.Action == BulkDelete || ops[i].item.Action == BulkUpdate):
+			// документа нет в индексе: удалять нечего, а обновлять не нужно -
+			// при индексации он получит актуальные значения
+		case res.Status == http.StatusConflict && ops[i].item.Action == BulkCreate:
+			// документ уже записан более свежим изменением
+		case res.Status == http.StatusTooManyRequests:
+			retry = append(retry, ops[i])
+		default:
+			b.fail(ops[i:i+1], res.Status, res.Error.Type, res.Error.Reason)
+		}
+	}
+	return retry, nil
+}
+
+type bulkResponseItem struct {
+	Status int `json:"status"`
+	Error  struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+func (b *BulkIndexer) fail(ops []bulkOp, status int, errType, reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, op := range ops {
+		itemErr := ItemError{Action: op.item.Action, ID: op.item.ID, Status: status, Type: errType, Reason: reason}
+		log.Printf("документ не обработан Elasticsearch: %v", itemErr)
+		b.failed = append(b.failed, itemErr)
+	}
+}
+
+func (b *BulkIndexer) failRequest(ops []bulkOp, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	log.Printf("bulk-запрос из %d операций не выполнен: %v", len(ops), err)
+	if b.reqErr == nil {
+		b.reqErr = err
+	}
+}
+
+func (b *BulkIndexer) backoff(attempt int) time.Duration {
+	base := b.cfg.RetryBackoff
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	d := base << (attempt - 1)
+	if d <= 0 || d > 30*time.Second {
+		return 30 * time.Second
+	}
+	return d
+}
@@ -0,0 +1,166 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// fakeBulk отвечает на bulk-запросы: respond получает номер запроса и
+// операции из него и возвращает HTTP-статус и статусы документов.
+func fakeBulk(t *testing.T, respond func(request int, actions []string) (int, []int)) *elasticsearch.Client {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var actions []string
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var meta map[string]json.RawMessage
+			if err := json.Unmarshal(scanner.Bytes(), &meta); err != nil {
+				continue
+			}
+			for action := range meta {
+				if action == BulkIndex || action == BulkCreate || action == BulkDelete || action == BulkUpdate {
+					actions = append(actions, action)
+				}
+			}
+		}
+
+		status, statuses := respond(int(requests.Add(1)), actions)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status >= 300 {
+			fmt.Fprint(w, `{"error": "rejected"}`)
+			return
+		}
+
+		items := make([]string, len(actions))
+		hasErrors := false
+		for i, action := range actions {
+			if statuses[i] >= 300 {
+				hasErrors = true
+				items[i] = fmt.Sprintf(`{%q: {"status": %d, "error": {"type": "test_exception", "reason": "rejected"}}}`, action, statuses[i])
+			} else {
+				items[i] = fmt.Sprintf(`{%q: {"status": %d}}`, action, statuses[i])
+			}
+		}
+		fmt.Fprintf(w, `{"errors": %t, "items": [%s]}`, hasErrors, strings.Join(items, ","))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{server.URL}, DisableRetry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestBulkIndexerItemStatuses(t *testing.T) {
+	tests := []struct {
+		name       string
+		item       BulkItem
+		status     int
+		wantFailed bool
+	}{
+		{"created", BulkItem{Action: BulkIndex, ID: "1", Doc: map[string]any{}}, http.StatusCreated, false},
+		{"create conflicts with newer document", BulkItem{Action: BulkCreate, ID: "1", Doc: map[string]any{}}, http.StatusConflict, false},
+		{"delete of missing document", BulkItem{Action: BulkDelete, ID: "1"}, http.StatusNotFound, false},
+		{"update of missing document", BulkItem{Action: BulkUpdate, ID: "1", Doc: map[string]any{}}, http.StatusNotFound, false},
+		{"index conflict", BulkItem{Action: BulkIndex, ID: "1", Doc: map[string]any{}}, http.StatusConflict, true},
+		{"mapping error", BulkItem{Action: BulkIndex, ID: "1", Doc: map[string]any{}}, http.StatusBadRequest, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeBulk(t, func(_ int, actions []string) (int, []int) {
+				return http.StatusOK, []int{tt.status}
+			})
+
+			indexer := NewBulkIndexer(client, "products", BulkConfig{})
+			if err := indexer.Add(tt.item); err != nil {
+				t.Fatal(err)
+			}
+			err := indexer.Close()
+
+			var bulkErr *BulkError
+			if tt.wantFailed != errors.As(err, &bulkErr) {
+				t.Fatalf("expected failed %v, got %v", tt.wantFailed, err)
+			}
+			if tt.wantFailed && (len(bulkErr.Items) != 1 || bulkErr.Items[0].Status != tt.status) {
+				t.Errorf("unexpected item errors %+v", bulkErr.Items)
+			}
+		})
+	}
+}
+
+func TestBulkIndexerRequestErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		respond      func(request int, actions []string) (int, []int)
+		wantRequests int
+		wantBulkErr  bool
+		wantErr      bool
+	}{
+		{
+			name: "rejected document is retried",
+			respond: func(request int, actions []string) (int, []int) {
+				if request == 1 {
+					return http.StatusOK, []int{http.StatusTooManyRequests}
+				}
+				return http.StatusOK, []int{http.StatusOK}
+			},
+			wantRequests: 2,
+		},
+		{
+			name: "unavailable cluster is a request error",
+			respond: func(int, []string) (int, []int) {
+				return http.StatusInternalServerError, nil
+			},
+			wantRequests: 3,
+			wantErr:      true,
+		},
+		{
+			name: "malformed request fails its documents",
+			respond: func(int, []string) (int, []int) {
+				return http.StatusBadRequest, nil
+			},
+			wantRequests: 1,
+			wantErr:      true,
+			wantBulkErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			client := fakeBulk(t, func(request int, actions []string) (int, []int) {
+				requests.Store(int32(request))
+				return tt.respond(request, actions)
+			})
+
+			indexer := NewBulkIndexer(client, "products", BulkConfig{MaxRetries: 2, RetryBackoff: 1})
+			if err := indexer.Add(BulkItem{Action: BulkIndex, ID: "1", Doc: map[string]any{}}); err != nil {
+				t.Fatal(err)
+			}
+			err := indexer.Close()
+
+			var bulkErr *BulkError
+			if (err != nil) != tt.wantErr || errors.As(err, &bulkErr) != tt.wantBulkErr {
+				t.Errorf("expected error %v (per document %v), got %v", tt.wantErr, tt.wantBulkErr, err)
+			}
+			if got := int(requests.Load()); got != tt.wantRequests {
+				t.Errorf("expected %d requests, got %d", tt.wantRequests, got)
+			}
+		})
+	}
+}
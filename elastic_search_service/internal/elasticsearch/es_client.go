@@ -1,19 +1,27 @@
 package elasticsearch
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"online-shop/config"
-	"online-shop/internal/models"
+	"sync"
 
 	"github.com/elastic/go-elasticsearch/v8"
 )
 
+// productsAlias - алиас, через который сервис читает и пишет товары.
+const productsAlias = "products"
+
 type ESClient struct {
-	Client *elasticsearch.Client
+	Client  *elasticsearch.Client
+	bulk    BulkConfig
+	ranking config.RankingConfig
+
+	// Пока идёт переиндексация, изменения дублируются в shadow, а удалённые
+	// товары запоминаются, чтобы загрузка из БД их не вернула.
+	mu            sync.Mutex
+	shadow        string
+	shadowDeletes map[string]struct{}
 }
 
 
@@ -36,108 +44,93 @@ func NewESClient() (*ESClient, error) {
 	}
 	log.Println(res)
 
-	return &ESClient{Client: client}, nil
+	return &ESClient{
+		Client: client,
+		bulk: BulkConfig{
+			FlushSize:    input_cfg.BulkFlushSize,
+			Workers:      input_cfg.BulkWorkers,
+			MaxRetries:   input_cfg.BulkMaxRetries,
+			RetryBackoff: input_cfg.BulkRetryBackoff,
+		},
+		ranking: input_cfg.Ranking,
+	}, nil
 }
 
 
-func (es *ESClient) IndexProducts(products []models.Product) error {
-	for _, product := range products {
-		data, err := json.Marshal(product)
-		if err != nil {
-			return fmt.Errorf("ошибка сериализации продукта: %w", err)
+// Bulk применяет операции через Bulk API и ждёт их завершения. Ошибки по
+// отдельным документам возвращаются как *BulkError, любая другая ошибка
+// значит, что часть операций не дошла до Elasticsearch.
+func (es *ESClient) Bulk(items []BulkItem) error {
+	es.mu.Lock()
+	shadow := es.shadow
+	if shadow != "" {
+		for _, item := range items {
+			if item.Action == BulkDelete {
+				es.shadowDeletes[item.ID] = struct{}{}
+			} else {
+				delete(es.shadowDeletes, item.ID)
+			}
 		}
+	}
+	es.mu.Unlock()
 
-		req := bytes.NewReader(data)
-		res, err := es.Client.Index("products", req, es.Client.Index.WithDocumentID(fmt.Sprintf("%d", product.ID)))
-		if err != nil {
-			return fmt.Errorf("ошибка при индексации продукта: %w", err)
+	err := es.BulkTo(productsAlias, items)
+	if shadow != "" {
+		if err := es.BulkTo(shadow, items); err != nil {
+			log.Printf("ошибка при записи в новый индекс %s: %v", shadow, err)
 		}
-		defer res.Body.Close()
-
-		log.Printf("Товар %s добавлен/обновлен в Elasticsearch", product.Name)
 	}
-	return nil
+	return err
 }
 
-
-func (es *ESClient) SearchProducts(query string) ([]models.Product, error) {
-	searchBody := map[string]interface{}{
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"should": []map[string]interface{}{
-					{
-						"multi_match": map[string]interface{}{
-							"query":     query,
-							"fields":    []string{"name^3", "description^2", "category"},
-							"fuzziness": "AUTO", 
-						},
-					},
-					
-					{
-						"prefix": map[string]interface{}{
-							"name": map[string]interface{}{
-								"value": query,
-								"boost": 2, 
-							},
-						},
-					},
-					
-					{
-						"match_phrase": map[string]interface{}{
-							"name": map[string]interface{}{
-								"query": query,
-								"slop":  2, 
-							},
-						},
-					},
-				},
-				"minimum_should_match": "1",
-			},
-		},
-	}
-
-	body, err := json.Marshal(searchBody)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка сериализации поискового запроса: %w", err)
-	}
-
-	res, err := es.Client.Search(
-		es.Client.Search.WithIndex("products"),
-		es.Client.Search.WithBody(bytes.NewReader(body)),
-		es.Client.Search.WithPretty(),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка выполнения поискового запроса: %w", err)
+// BulkTo применяет операции к конкретному индексу. Вызов синхронный: на
+// каждый вызов создаётся свой индексатор и закрывается до возврата, поэтому
+// результат относится только к переданным items. На это опираются
+// потребители Kafka и Syncer: по результату они подтверждают смещения и
+// сдвигают отметки. Копить операции между вызовами должен сам вызывающий.
+func (es *ESClient) BulkTo(index string, items []BulkItem) error {
+	indexer := NewBulkIndexer(es.Client, index, es.bulk)
+	for _, item := range items {
+		if err := indexer.Add(item); err != nil {
+			indexer.Close()
+			return err
+		}
 	}
-	defer res.Body.Close()
+	return indexer.Close()
+}
 
-	resBody, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка чтения ответа от Elasticsearch: %w", err)
-	}
 
-	var searchResult struct {
-		Hits struct {
-			Hits []struct {
-				Source models.Product `json:"_source"`
-			} `json:"hits"`
-		} `json:"hits"`
-	}
-	if err := json.Unmarshal(resBody, &searchResult); err != nil {
-		return nil, fmt.Errorf("ошибка декодирования ответа от Elasticsearch: %w", err)
-	}
+// StartShadow начинает дублировать изменения в index.
+func (es *ESClient) StartShadow(index string) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	es.shadow = index
+	es.shadowDeletes = make(map[string]struct{})
+}
 
-	var products []models.Product
-	for _, hit := range searchResult.Hits.Hits {
-		products = append(products, hit.Source)
+// ShadowDeletes возвращает товары, удалённые с момента StartShadow или
+// предыдущего вызова, и очищает список.
+func (es *ESClient) ShadowDeletes() []string {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	ids := make([]string, 0, len(es.shadowDeletes))
+	for id := range es.shadowDeletes {
+		ids = append(ids, id)
 	}
+	es.shadowDeletes = make(map[string]struct{})
+	return ids
+}
 
-	return products, nil
+func (es *ESClient) StopShadow() {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	es.shadow = ""
+	es.shadowDeletes = nil
 }
 
 
 func (es *ESClient) DeleteProduct(productID int) error {
-	res, err := es.Client.Delete("products", fmt.Sprintf("%d", productID))
+	res, err := es.Client.Delete(productsAlias, fmt.Sprintf("%d", productID))
 	if err != nil {
 		return fmt.Errorf("ошибка при удалении продукта: %w", err)
 	}
@@ -0,0 +1,191 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+const productsTemplateName = "products"
+
+// EnsureIndex создаёт шаблон индекса и, если алиаса products ещё нет,
+// первую версию индекса. reindex == true означает, что текущий индекс нужно
+// перестроить: это обычный индекс products с динамическим маппингом от
+// прежних версий сервиса или индекс, созданный по старому шаблону.
+func (es *ESClient) EnsureIndex() (reindex bool, err error) {
+	res, err := es.Client.Indices.PutIndexTemplate(productsTemplateName, strings.NewReader(templateBody()))
+	if err := checkResponse(res, err, "ошибка при создании шаблона индекса"); err != nil {
+		return false, err
+	}
+
+	current, err := es.aliasIndices()
+	if err != nil {
+		return false, err
+	}
+	if len(current) > 0 {
+		version, err := es.mappingVersion()
+		if err != nil {
+			return false, err
+		}
+		return version < mappingVersion, nil
+	}
+
+	res, err = es.Client.Indices.Exists([]string{productsAlias})
+	if err != nil {
+		return false, fmt.Errorf("ошибка при проверке индекса: %w", err)
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return true, nil
+	}
+
+	index, err := es.CreateVersionedIndex()
+	if err != nil {
+		return false, err
+	}
+	if _, err := es.SwapAlias(index); err != nil {
+		return false, err
+	}
+	log.Printf("создан индекс %s с алиасом %s", index, productsAlias)
+	return false, nil
+}
+
+// CreateVersionedIndex создаёт пустой индекс products-<время>; настройки и
+// маппинг берутся из шаблона.
+func (es *ESClient) CreateVersionedIndex() (string, error) {
+	index := productsAlias + "-" + time.Now().UTC().Format("20060102150405")
+	res, err := es.Client.Indices.Create(index)
+	if err := checkResponse(res, err, "ошибка при создании индекса "+index); err != nil {
+		return "", err
+	}
+	return index, nil
+}
+
+// SwapAlias атомарно переключает алиас products на index и возвращает
+// индексы, на которые он указывал раньше. Индекс со старым динамическим
+// маппингом удаляется в том же запросе, иначе алиас с его именем не создать.
+func (es *ESClient) SwapAlias(index string) ([]string, error) {
+	old, err := es.aliasIndices()
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []map[string]any{
+		{"add": map[string]string{"index": index, "alias": productsAlias}},
+	}
+	for _, name := range old {
+		actions = append(actions, map[string]any{"remove": map[string]string{"index": name, "alias": productsAlias}})
+	}
+	if len(old) == 0 {
+		res, err := es.Client.Indices.Exists([]string{productsAlias})
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при проверке индекса: %w", err)
+		}
+		res.Body.Close()
+		if res.StatusCode == http.StatusOK {
+			actions = append(actions, map[string]any{"remove_index": map[string]string{"index": productsAlias}})
+		}
+	}
+
+	body, err := json.Marshal(map[string]any{"actions": actions})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации запроса алиасов: %w", err)
+	}
+	res, err := es.Client.Indices.UpdateAliases(strings.NewReader(string(body)))
+	if err := checkResponse(res, err, "ошибка при переключении алиаса"); err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+// DeleteIndices удаляет старые версии индекса после переключения алиаса.
+func (es *ESClient) DeleteIndices(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	res, err := es.Client.Indices.Delete(names)
+	return checkResponse(res, err, "ошибка при удалении индексов")
+}
+
+// ReloadSearchAnalyzers перечитывает файл синонимов во всех индексах за
+// алиасом.
+func (es *ESClient) ReloadSearchAnalyzers() error {
+	res, err := es.Client.Indices.ReloadSearchAnalyzers([]string{productsAlias})
+	return checkResponse(res, err, "ошибка при перезагрузке анализаторов")
+}
+
+// mappingVersion возвращает наименьшую версию маппинга среди индексов за
+// алиасом; индексы без _meta.version считаются версией 1.
+func (es *ESClient) mappingVersion() (int, error) {
+	res, err := es.Client.Indices.GetMapping(es.Client.Indices.GetMapping.WithIndex(productsAlias))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при получении маппинга: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, fmt.Errorf("ошибка при получении маппинга: %s", res.String())
+	}
+
+	var indices map[string]struct {
+		Mappings struct {
+			Meta struct {
+				Version int `json:"version"`
+			} `json:"_meta"`
+		} `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return 0, fmt.Errorf("ошибка декодирования маппинга: %w", err)
+	}
+
+	version := mappingVersion
+	for _, index := range indices {
+		version = min(version, max(index.Mappings.Meta.Version, 1))
+	}
+	return version, nil
+}
+
+// aliasIndices возвращает индексы, на которые указывает алиас products.
+func (es *ESClient) aliasIndices() ([]string, error) {
+	res, err := es.Client.Indices.GetAlias(es.Client.Indices.GetAlias.WithName(productsAlias))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении алиаса: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("ошибка при получении алиаса: %s", res.String())
+	}
+
+	var aliases map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&aliases); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования алиасов: %w", err)
+	}
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func checkResponse(res *esapi.Response, err error, msg string) error {
+	if err != nil {
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("%s: %s %s", msg, res.Status(), strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package elasticsearch
+
+import "fmt"
+
+// mappingVersion хранится в _meta индекса. Его нужно увеличивать при каждом
+// изменении шаблона: при старте сервис переиндексирует каталог, если
+// текущий индекс построен по более старой версии.
+const mappingVersion = 4
+
+// productsTemplate применяется ко всем версиям индекса products-*. Алиас
+// products указывает на текущую версию, так что маппинг меняется через
+// переиндексацию без простоя поиска.
+//
+// ru_en стеммит оба языка: русский стеммер не трогает латиницу, английский -
+// кириллицу. name.autocomplete индексирует префиксы слов для поиска по мере
+// ввода, name.shingle - для исправления опечаток phrase-суггестером,
+// keyword-подполя нужны для фильтров, агрегаций и сортировки. Поле suggest
+// заполняет ProductDoc для completion-подсказок; в _source оно не хранится.
+//
+// Синонимы применяются только при поиске (ru_en_search) и читаются из файла
+// analysis/synonyms.txt в каталоге конфигурации Elasticsearch. Фильтр updateable,
+// поэтому после записи файла хватает ReloadSearchAnalyzers - переиндексация
+// не нужна.
+// Версия подставляется из mappingVersion в templateBody.
+const productsTemplate = `{
+  "index_patterns": ["products-*"],
+  "priority": 100,
+  "template": {
+    "settings": {
+      "analysis": {
+        "filter": {
+          "russian_stop": {"type": "stop", "stopwords": "_russian_"},
+          "russian_stemmer": {"type": "stemmer", "language": "russian"},
+          "english_stop": {"type": "stop", "stopwords": "_english_"},
+          "english_stemmer": {"type": "stemmer", "language": "english"},
+          "english_possessive_stemmer": {"type": "stemmer", "language": "possessive_english"},
+          "autocomplete_filter": {"type": "edge_ngram", "min_gram": 2, "max_gram": 20},
+          "shingle_filter": {"type": "shingle", "min_shingle_size": 2, "max_shingle_size": 3},
+          "product_synonyms": {"type": "synonym_graph", "synonyms_path": "analysis/synonyms.txt", "updateable": true, "lenient": true}
+        },
+        "analyzer": {
+          "ru_en": {
+            "tokenizer": "standard",
+            "filter": ["english_possessive_stemmer", "lowercase", "russian_stop", "english_stop", "russian_stemmer", "english_stemmer"]
+          },
+          "ru_en_search": {
+            "tokenizer": "standard",
+            "filter": ["english_possessive_stemmer", "lowercase", "product_synonyms", "russian_stop", "english_stop", "russian_stemmer", "english_stemmer"]
+          },
+          "autocomplete": {
+            "tokenizer": "standard",
+            "filter": ["lowercase", "autocomplete_filter"]
+          },
+          "autocomplete_search": {
+            "tokenizer": "standard",
+            "filter": ["lowercase"]
+          },
+          "shingle": {
+            "tokenizer": "standard",
+            "filter": ["lowercase", "shingle_filter"]
+          }
+        },
+        "normalizer": {
+          "lowercase": {"type": "custom", "filter": ["lowercase"]}
+        }
+      }
+    },
+    "mappings": {
+      "_meta": {"version": %d},
+      "_source": {"excludes": ["suggest"]},
+      "dynamic": false,
+      "properties": {
+        "id": {"type": "long"},
+        "name": {
+          "type": "text",
+          "analyzer": "ru_en",
+          "search_analyzer": "ru_en_search",
+          "fields": {
+            "keyword": {"type": "keyword", "ignore_above": 256},
+            "autocomplete": {"type": "text", "analyzer": "autocomplete", "search_analyzer": "autocomplete_search"},
+            "shingle": {"type": "text", "analyzer": "shingle"}
+          }
+        },
+        "description": {"type": "text", "analyzer": "ru_en", "search_analyzer": "ru_en_search"},
+        "price": {"type": "scaled_float", "scaling_factor": 100},
+        "category": {
+          "type": "text",
+          "analyzer": "ru_en",
+          "search_analyzer": "ru_en_search",
+          "fields": {
+            "keyword": {"type": "keyword", "ignore_above": 256},
+            "normalized": {"type": "keyword", "normalizer": "lowercase", "ignore_above": 256}
+          }
+        },
+        "created_at": {"type": "date"},
+        "images": {"type": "keyword", "index": false},
+        "avg_rating": {"type": "float"},
+        "review_count": {"type": "integer"},
+        "popularity": {"type": "long"},
+        "suggest": {
+          "type": "completion",
+          "analyzer": "simple",
+          "contexts": [{"name": "category", "type": "category", "path": "category"}]
+        }
+      }
+    }
+  }
+}`
+
+func templateBody() string {
+	return fmt.Sprintf(productsTemplate, mappingVersion)
+}
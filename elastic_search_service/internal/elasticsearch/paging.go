@@ -0,0 +1,107 @@
+package elasticsearch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	SortRelevance = "relevance"
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortNewest    = "newest"
+	SortRating    = "rating"
+)
+
+// MaxResultWindow - предел from+size для обычной пагинации, как
+// index.max_result_window в Elasticsearch. Дальше листают через search_after.
+const MaxResultWindow = 10000
+
+// pitKeepAlive - сколько Elasticsearch держит point-in-time между запросами
+// страниц.
+const pitKeepAlive = "1m"
+
+var (
+	ErrInvalidSort      = errors.New("неизвестная сортировка")
+	ErrInvalidPageToken = errors.New("некорректный или устаревший токен страницы")
+	ErrResultWindow     = fmt.Errorf("from+size больше %d, используйте глубокую пагинацию", MaxResultWindow)
+)
+
+var sortOrders = map[string][]map[string]string{
+	SortRelevance: {{"_score": "desc"}},
+	SortPriceAsc:  {{"price": "asc"}},
+	SortPriceDesc: {{"price": "desc"}},
+	SortNewest:    {{"created_at": "desc"}},
+	SortRating:    {{"avg_rating": "desc"}, {"review_count": "desc"}},
+}
+
+// sortClause возвращает сортировку с полем для разрешения равенств: без
+// него порядок товаров с одинаковыми значениями меняется между страницами.
+func sortClause(sort string, tiebreak string) ([]map[string]string, error) {
+	order, ok := sortOrders[sort]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSort, sort)
+	}
+	return append(append([]map[string]string(nil), order...), map[string]string{tiebreak: "asc"}), nil
+}
+
+// pageToken - состояние глубокой пагинации, которое клиент передаёт обратно
+// в page_token. Значения сортировки хранятся как есть: в _shard_doc бывают
+// числа больше 2^53, которые float64 исказил бы.
+type pageToken struct {
+	PIT   string            `json:"pit"`
+	After []json.RawMessage `json:"after"`
+	Sort  string            `json:"sort"`
+}
+
+func (t pageToken) encode() (string, error) {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodePageToken(s string) (*pageToken, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	var t pageToken
+	if err := json.Unmarshal(raw, &t); err != nil || t.PIT == "" || len(t.After) == 0 {
+		return nil, ErrInvalidPageToken
+	}
+	return &t, nil
+}
+
+func (es *ESClient) openPIT() (string, error) {
+	res, err := es.Client.OpenPointInTime([]string{productsAlias}, pitKeepAlive)
+	if err != nil {
+		return "", fmt.Errorf("ошибка открытия point-in-time: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return "", fmt.Errorf("ошибка открытия point-in-time: %s", res.String())
+	}
+
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("ошибка декодирования point-in-time: %w", err)
+	}
+	return body.ID, nil
+}
+
+func (es *ESClient) closePIT(id string) error {
+	body, err := json.Marshal(map[string]string{"id": id})
+	if err != nil {
+		return err
+	}
+	res, err := es.Client.ClosePointInTime(es.Client.ClosePointInTime.WithBody(strings.NewReader(string(body))))
+	return checkResponse(res, err, "ошибка закрытия point-in-time")
+}
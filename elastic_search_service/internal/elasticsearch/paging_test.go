@@ -0,0 +1,147 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSortClause(t *testing.T) {
+	tests := []struct {
+		sort     string
+		tiebreak string
+		want     string
+		wantErr  error
+	}{
+		{SortRelevance, "id", `[{"_score": "desc"}, {"id": "asc"}]`, nil},
+		{SortPriceAsc, "_shard_doc", `[{"price": "asc"}, {"_shard_doc": "asc"}]`, nil},
+		{SortRating, "id", `[{"avg_rating": "desc"}, {"review_count": "desc"}, {"id": "asc"}]`, nil},
+		{"cheapest", "id", ``, ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			got, err := sortClause(tt.sort, tt.tiebreak)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+
+	// тайбрейк не должен дописываться в общий порядок сортировки
+	sortClause(SortRating, "id")
+	if len(sortOrders[SortRating]) != 2 {
+		t.Errorf("sortClause modified sortOrders: %v", sortOrders[SortRating])
+	}
+}
+
+func TestPageTokenKeepsLargeSortValues(t *testing.T) {
+	// _shard_doc больше 2^53 исказился бы при разборе в float64
+	token := pageToken{PIT: "pit-1", After: []json.RawMessage{json.RawMessage(`4.5`), json.RawMessage(`9007199254740993`)}, Sort: SortRelevance}
+
+	encoded, err := token.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodePageToken(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PIT != token.PIT || got.Sort != token.Sort || string(got.After[1]) != "9007199254740993" {
+		t.Errorf("expected %+v, got %+v", token, got)
+	}
+}
+
+func TestDecodePageTokenRejectsInvalid(t *testing.T) {
+	noAfter, _ := pageToken{PIT: "pit-1", Sort: SortRelevance}.encode()
+	noPIT, _ := pageToken{After: []json.RawMessage{json.RawMessage(`1`)}, Sort: SortRelevance}.encode()
+
+	for name, token := range map[string]string{"not base64": "@@@", "not json": "bm90IGpzb24", "no search_after": noAfter, "no pit": noPIT} {
+		if _, err := decodePageToken(token); !errors.Is(err, ErrInvalidPageToken) {
+			t.Errorf("%s: expected ErrInvalidPageToken, got %v", name, err)
+		}
+	}
+}
+
+func TestSearchProductsPaging(t *testing.T) {
+	token, err := pageToken{PIT: "pit-1", After: []json.RawMessage{json.RawMessage(`1.5`), json.RawMessage(`42`)}, Sort: SortRelevance}.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		params  SearchParams
+		path    string
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "offset page",
+			params: SearchParams{From: 20, Size: 10},
+			path:   "/products/_search",
+			want:   `{"from": 20, "sort": [{"_score": "desc"}, {"id": "asc"}]}`,
+		},
+		{
+			name:   "first deep page",
+			params: SearchParams{Deep: true, Size: 10},
+			path:   "/_search",
+			want:   `{"pit": {"id": "pit-new", "keep_alive": "1m"}, "sort": [{"_score": "desc"}, {"_shard_doc": "asc"}]}`,
+		},
+		{
+			name:   "next deep page",
+			params: SearchParams{PageToken: token, Size: 10},
+			path:   "/_search",
+			want: `{"pit": {"id": "pit-1", "keep_alive": "1m"}, "search_after": [1.5, 42],
+				"sort": [{"_score": "desc"}, {"_shard_doc": "asc"}]}`,
+		},
+		{
+			name:    "token of another sort",
+			params:  SearchParams{PageToken: token, Sort: SortPriceAsc},
+			wantErr: ErrInvalidPageToken,
+		},
+		{
+			name:    "beyond result window",
+			params:  SearchParams{From: MaxResultWindow, Size: 10},
+			wantErr: ErrResultWindow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es, fake := newFakeES(t, map[string]string{
+				"/products/_search": emptySearchResponse,
+				"/_search":          emptySearchResponse,
+				"/products/_pit":    `{"id": "pit-new"}`,
+				"/_pit":             `{}`,
+			})
+
+			_, err := es.SearchProducts(tt.params)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body := fake.body(tt.path)
+			got := map[string]any{"sort": body["sort"]}
+			for _, key := range []string{"from", "pit", "search_after"} {
+				if v, ok := body[key]; ok {
+					got[key] = v
+				}
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
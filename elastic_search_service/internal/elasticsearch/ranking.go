@@ -0,0 +1,47 @@
+package elasticsearch
+
+// rankedQuery оборачивает текстовый запрос в function_score: релевантность
+// умножается (или смешивается по BoostMode) на популярность и оценку
+// рейтинга. Рейтинг учитывается только у товаров с MinReviews отзывами и
+// больше, иначе товар без отзывов оказался бы ниже товара с единицей.
+func (es *ESClient) rankedQuery(query map[string]any) map[string]any {
+	r := es.ranking
+
+	var functions []map[string]any
+	if r.PopularityFactor > 0 {
+		functions = append(functions, map[string]any{
+			"field_value_factor": map[string]any{
+				"field":    "popularity",
+				"factor":   r.PopularityFactor,
+				"modifier": r.PopularityModifier,
+				"missing":  0,
+			},
+		})
+	}
+	if r.RatingWeight > 0 {
+		functions = append(functions, map[string]any{
+			"filter": map[string]any{"range": map[string]any{"review_count": map[string]any{"gte": r.MinReviews}}},
+			"gauss": map[string]any{
+				"avg_rating": map[string]any{
+					"origin": r.RatingOrigin,
+					"scale":  r.RatingScale,
+					"decay":  r.RatingDecay,
+				},
+			},
+			"weight": r.RatingWeight,
+		})
+	}
+	if len(functions) == 0 {
+		return query
+	}
+
+	return map[string]any{
+		"function_score": map[string]any{
+			"query":      query,
+			"functions":  functions,
+			"score_mode": "multiply",
+			"boost_mode": r.BoostMode,
+			"max_boost":  r.MaxBoost,
+		},
+	}
+}
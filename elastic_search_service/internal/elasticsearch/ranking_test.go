@@ -0,0 +1,64 @@
+package elasticsearch
+
+import (
+	"online-shop/config"
+	"testing"
+)
+
+func TestRankedQuery(t *testing.T) {
+	query := map[string]any{"match_all": map[string]any{}}
+	popularity := `{"field_value_factor": {"field": "popularity", "factor": 1.2, "modifier": "log2p", "missing": 0}}`
+	rating := `{"filter": {"range": {"review_count": {"gte": 3}}},
+		"gauss": {"avg_rating": {"origin": 5, "scale": 1.5, "decay": 0.5}}, "weight": 2}`
+
+	tests := []struct {
+		name    string
+		ranking config.RankingConfig
+		want    string
+	}{
+		{
+			name:    "all functions disabled",
+			ranking: config.RankingConfig{BoostMode: "multiply"},
+			want:    `{"match_all": {}}`,
+		},
+		{
+			name:    "popularity only",
+			ranking: config.RankingConfig{PopularityFactor: 1.2, PopularityModifier: "log2p", BoostMode: "sum", MaxBoost: 10},
+			want: `{"function_score": {"query": {"match_all": {}}, "functions": [` + popularity + `],
+				"score_mode": "multiply", "boost_mode": "sum", "max_boost": 10}}`,
+		},
+		{
+			name: "popularity and rating",
+			ranking: config.RankingConfig{
+				PopularityFactor: 1.2, PopularityModifier: "log2p",
+				RatingWeight: 2, RatingOrigin: 5, RatingScale: 1.5, RatingDecay: 0.5, MinReviews: 3,
+				BoostMode: "multiply", MaxBoost: 3,
+			},
+			want: `{"function_score": {"query": {"match_all": {}}, "functions": [` + popularity + `, ` + rating + `],
+				"score_mode": "multiply", "boost_mode": "multiply", "max_boost": 3}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := &ESClient{ranking: tt.ranking}
+			assertJSON(t, es.rankedQuery(query), tt.want)
+		})
+	}
+}
+
+func TestSearchProductsMerchRulesWrapRankedQuery(t *testing.T) {
+	es, fake := newFakeES(t, map[string]string{"/products/_search": emptySearchResponse})
+	es.ranking = config.RankingConfig{PopularityFactor: 1, PopularityModifier: "none", BoostMode: "multiply", MaxBoost: 2}
+
+	if _, err := es.SearchProducts(SearchParams{Pinned: []int64{7}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// закреплённые товары идут первыми независимо от популярности, поэтому
+	// function_score находится внутри organic
+	assertJSON(t, fake.body("/products/_search")["query"], `{"pinned": {"ids": ["7"], "organic": {"function_score": {
+		"query": {"match_all": {}},
+		"functions": [{"field_value_factor": {"field": "popularity", "factor": 1, "modifier": "none", "missing": 0}}],
+		"score_mode": "multiply", "boost_mode": "multiply", "max_boost": 2}}}}`)
+}
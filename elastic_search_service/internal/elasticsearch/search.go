@@ -0,0 +1,501 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"online-shop/internal/models"
+	"strconv"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// SearchParams - текст запроса и выбранные фильтры. Пустой Query ищет по
+// всем товарам, nil-границы не ограничивают выборку.
+type SearchParams struct {
+	Query      string
+	Categories []string
+	MinPrice   *float64
+	MaxPrice   *float64
+	MinRating  *float64
+
+	// PriceInterval - ширина корзины гистограммы цен.
+	PriceInterval float64
+
+	Sort string
+	From int
+	Size int
+
+	// Deep включает глубокую пагинацию: поиск идёт по point-in-time, а
+	// следующая страница запрашивается по PageToken из ответа.
+	Deep      bool
+	PageToken string
+
+	// Highlight включает подсветку совпадений; nil - без подсветки.
+	Highlight *HighlightParams
+
+	// Настройки мерчендайзинга: веса полей текстового поиска (nil -
+	// DefaultBoosts), товары, закреплённые в начале выдачи, и скрытые товары.
+	Boosts  map[string]float64
+	Pinned  []int64
+	Blocked []int64
+}
+
+// BoostableFields - поля, для которых можно задать вес в профиле.
+var BoostableFields = []string{"name", "description", "category"}
+
+var DefaultBoosts = map[string]float64{"name": 3, "description": 2, "category": 1}
+
+// HighlightParams настраивает подсветку. При SnippetOnly полное описание не
+// возвращается, вместо него в Hit.Snippet - один фрагмент.
+type HighlightParams struct {
+	PreTag       string
+	PostTag      string
+	FragmentSize int
+	SnippetOnly  bool
+}
+
+type SearchResult struct {
+	// SearchID связывает поиск с кликами по его результатам (/search/click).
+	SearchID      string `json:"search_id,omitempty"`
+	Total         int64  `json:"total"`
+	TookMs        int64  `json:"took_ms"`
+	Hits          []Hit  `json:"hits"`
+	Facets        Facets `json:"facets"`
+	NextPageToken string `json:"next_page_token,omitempty"`
+
+	// DidYouMean - исправления запроса, если по нему почти ничего не нашлось.
+	DidYouMean []string `json:"did_you_mean,omitempty"`
+}
+
+// Hit - найденный товар и его релевантность. Score заполнен и при сортировке
+// по полям, чтобы клиент мог показать, насколько товар подходит к запросу.
+type Hit struct {
+	Score *float64 `json:"_score"`
+	models.Product
+	Highlight *HitHighlight `json:"highlight,omitempty"`
+	Snippet   string        `json:"snippet,omitempty"`
+}
+
+type HitHighlight struct {
+	Name        string   `json:"name,omitempty"`
+	Description []string `json:"description,omitempty"`
+}
+
+type Facets struct {
+	Categories []FacetBucket `json:"categories"`
+	Prices     []PriceBucket `json:"prices"`
+	Ratings    []FacetBucket `json:"ratings"`
+}
+
+type FacetBucket struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+type PriceBucket struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int64   `json:"count"`
+}
+
+const (
+	facetCategories = "categories"
+	facetPrices     = "prices"
+	facetRatings    = "ratings"
+)
+
+// ratingSteps - корзины «N звёзд и выше».
+var ratingSteps = []int{4, 3, 2, 1}
+
+// SearchProducts ищет товары и считает фасеты. Фильтры применяются через
+// post_filter, а каждая агрегация учитывает все фильтры, кроме своего, так
+// что при выборе нескольких значений одного фасета счётчики не обнуляются.
+//
+// Обычные страницы листаются через From/Size. При Deep или PageToken поиск
+// идёт по point-in-time с search_after, и в ответе возвращается токен
+// следующей страницы, пока результаты не закончатся.
+func (es *ESClient) SearchProducts(params SearchParams) (_ *SearchResult, retErr error) {
+	if params.PriceInterval <= 0 {
+		params.PriceInterval = 100
+	}
+	if params.Sort == "" {
+		params.Sort = SortRelevance
+	}
+	if params.Size <= 0 {
+		params.Size = 10
+	}
+
+	var token *pageToken
+	if params.PageToken != "" {
+		var err error
+		if token, err = decodePageToken(params.PageToken); err != nil {
+			return nil, err
+		}
+		if token.Sort != params.Sort {
+			return nil, fmt.Errorf("%w: токен получен для другой сортировки", ErrInvalidPageToken)
+		}
+	}
+	deep := params.Deep || token != nil
+
+	filters := searchFilters(params)
+	searchBody := map[string]any{
+		"query":            merchQuery(es.rankedQuery(textQuery(params.Query, params.Boosts)), params.Pinned, params.Blocked),
+		"size":             params.Size,
+		"track_total_hits": true,
+		"track_scores":     true,
+		"aggs": map[string]any{
+			facetCategories: facetAgg(filters, facetCategories, map[string]any{
+				"terms": map[string]any{"field": "category.keyword", "size": 50},
+			}),
+			facetPrices: facetAgg(filters, facetPrices, map[string]any{
+				"histogram": map[string]any{"field": "price", "interval": params.PriceInterval, "min_doc_count": 1},
+			}),
+			facetRatings: facetAgg(filters, facetRatings, map[string]any{
+				"range": map[string]any{"field": "avg_rating", "ranges": ratingRanges()},
+			}),
+		},
+	}
+	if len(filters) > 0 {
+		searchBody["post_filter"] = boolFilter(filters, "")
+	}
+	if params.Highlight != nil {
+		searchBody["highlight"] = highlightClause(*params.Highlight)
+		if params.Highlight.SnippetOnly {
+			searchBody["_source"] = map[string]any{"excludes": []string{"description"}}
+		}
+	}
+
+	opts := []func(*esapi.SearchRequest){}
+	if deep {
+		sort, err := sortClause(params.Sort, "_shard_doc")
+		if err != nil {
+			return nil, err
+		}
+		searchBody["sort"] = sort
+
+		pit := ""
+		if token != nil {
+			pit = token.PIT
+			searchBody["search_after"] = token.After
+		} else {
+			if pit, err = es.openPIT(); err != nil {
+				return nil, err
+			}
+			// Первая страница не удалась - клиент не получит токен, и
+			// point-in-time никто не закроет.
+			defer func() {
+				if retErr != nil {
+					es.closePIT(pit)
+				}
+			}()
+		}
+		// При поиске по point-in-time индекс в запросе не указывается.
+		searchBody["pit"] = map[string]string{"id": pit, "keep_alive": pitKeepAlive}
+	} else {
+		if params.From+params.Size > MaxResultWindow {
+			return nil, ErrResultWindow
+		}
+		sort, err := sortClause(params.Sort, "id")
+		if err != nil {
+			return nil, err
+		}
+		searchBody["sort"] = sort
+		searchBody["from"] = params.From
+		opts = append(opts, es.Client.Search.WithIndex(productsAlias))
+	}
+
+	body, err := json.Marshal(searchBody)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации поискового запроса: %w", err)
+	}
+
+	res, err := es.Client.Search(append(opts, es.Client.Search.WithBody(bytes.NewReader(body)))...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения поискового запроса: %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ответа от Elasticsearch: %w", err)
+	}
+	if res.IsError() {
+		if token != nil && res.StatusCode == http.StatusNotFound {
+			// point-in-time истёк или был закрыт
+			return nil, ErrInvalidPageToken
+		}
+		return nil, fmt.Errorf("ошибка поиска в Elasticsearch: %s %s", res.Status(), resBody)
+	}
+
+	var searchResult struct {
+		Took  int64  `json:"took"`
+		PITID string `json:"pit_id"`
+		Hits  struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Score     *float64            `json:"_score"`
+				Source    models.Product      `json:"_source"`
+				Sort      []json.RawMessage   `json:"sort"`
+				Highlight map[string][]string `json:"highlight"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations struct {
+			Categories struct {
+				Values struct {
+					Buckets []struct {
+						Key      string `json:"key"`
+						DocCount int64  `json:"doc_count"`
+					} `json:"buckets"`
+				} `json:"values"`
+			} `json:"categories"`
+			Prices struct {
+				Values struct {
+					Buckets []struct {
+						Key      float64 `json:"key"`
+						DocCount int64   `json:"doc_count"`
+					} `json:"buckets"`
+				} `json:"values"`
+			} `json:"prices"`
+			Ratings struct {
+				Values struct {
+					Buckets []struct {
+						Key      string `json:"key"`
+						DocCount int64  `json:"doc_count"`
+					} `json:"buckets"`
+				} `json:"values"`
+			} `json:"ratings"`
+		} `json:"aggregations"`
+	}
+	if err := json.Unmarshal(resBody, &searchResult); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования ответа от Elasticsearch: %w", err)
+	}
+
+	hits := searchResult.Hits.Hits
+	result := &SearchResult{
+		Total:  searchResult.Hits.Total.Value,
+		TookMs: searchResult.Took,
+		Hits:   make([]Hit, 0, len(hits)),
+	}
+	for _, hit := range hits {
+		h := Hit{Score: hit.Score, Product: hit.Source}
+		if params.Highlight != nil {
+			applyHighlight(&h, hit.Highlight, params.Highlight.SnippetOnly)
+		}
+		result.Hits = append(result.Hits, h)
+	}
+
+	if deep {
+		if len(hits) == params.Size {
+			next := pageToken{PIT: searchResult.PITID, After: hits[len(hits)-1].Sort, Sort: params.Sort}
+			if result.NextPageToken, err = next.encode(); err != nil {
+				return nil, fmt.Errorf("ошибка кодирования токена страницы: %w", err)
+			}
+		} else if err := es.closePIT(searchResult.PITID); err != nil {
+			log.Printf("не удалось закрыть point-in-time: %v", err)
+		}
+	}
+
+	if params.Query != "" && token == nil && params.From == 0 && result.Total < didYouMeanBelow {
+		if result.DidYouMean, err = es.DidYouMean(params.Query); err != nil {
+			log.Printf("не удалось получить исправления запроса: %v", err)
+		}
+	}
+
+	aggs := searchResult.Aggregations
+	for _, b := range aggs.Categories.Values.Buckets {
+		result.Facets.Categories = append(result.Facets.Categories, FacetBucket{Key: b.Key, Count: b.DocCount})
+	}
+	for _, b := range aggs.Prices.Values.Buckets {
+		result.Facets.Prices = append(result.Facets.Prices, PriceBucket{From: b.Key, To: b.Key + params.PriceInterval, Count: b.DocCount})
+	}
+	for _, b := range aggs.Ratings.Values.Buckets {
+		result.Facets.Ratings = append(result.Facets.Ratings, FacetBucket{Key: b.Key, Count: b.DocCount})
+	}
+
+	return result, nil
+}
+
+// highlightClause подсвечивает совпадения в названии целиком и во фрагментах
+// описания. no_match_size отдаёт начало описания, если совпадений в нём нет,
+// так что сниппет есть у каждого товара. Текст экранируется как HTML, чтобы
+// теги из описаний не смешивались с тегами подсветки.
+func highlightClause(hl HighlightParams) map[string]any {
+	fragments := 3
+	if hl.SnippetOnly {
+		fragments = 1
+	}
+	return map[string]any{
+		"pre_tags":  []string{hl.PreTag},
+		"post_tags": []string{hl.PostTag},
+		"encoder":   "html",
+		"fields": map[string]any{
+			"name": map[string]any{"number_of_fragments": 0},
+			"description": map[string]any{
+				"fragment_size":       hl.FragmentSize,
+				"number_of_fragments": fragments,
+				"no_match_size":       hl.FragmentSize,
+			},
+		},
+	}
+}
+
+func applyHighlight(h *Hit, fields map[string][]string, snippetOnly bool) {
+	if snippetOnly {
+		if fragments := fields["description"]; len(fragments) > 0 {
+			h.Snippet = fragments[0]
+		}
+		delete(fields, "description")
+	}
+
+	var hl HitHighlight
+	if name := fields["name"]; len(name) > 0 {
+		hl.Name = name[0]
+	}
+	hl.Description = fields["description"]
+	if hl.Name != "" || len(hl.Description) > 0 {
+		h.Highlight = &hl
+	}
+}
+
+func textQuery(query string, boosts map[string]float64) map[string]any {
+	if query == "" {
+		return map[string]any{"match_all": map[string]any{}}
+	}
+
+	return map[string]any{
+		"bool": map[string]any{
+			"should": []map[string]any{
+				{
+					"multi_match": map[string]any{
+						"query":     query,
+						"fields":    boostedFields(boosts),
+						"fuzziness": "AUTO",
+					},
+				},
+				{
+					// префиксы слов проиндексированы в name.autocomplete,
+					// поэтому prefix-запрос во время поиска не нужен
+					"match": map[string]any{
+						"name.autocomplete": map[string]any{
+							"query":    query,
+							"operator": "and",
+							"boost":    2,
+						},
+					},
+				},
+				{
+					"match_phrase": map[string]any{
+						"name": map[string]any{
+							"query": query,
+							"slop":  2,
+						},
+					},
+				},
+			},
+			"minimum_should_match": "1",
+		},
+	}
+}
+
+func boostedFields(boosts map[string]float64) []string {
+	if boosts == nil {
+		boosts = DefaultBoosts
+	}
+	fields := make([]string, 0, len(BoostableFields))
+	for _, field := range BoostableFields {
+		if boost, ok := boosts[field]; ok && boost > 0 {
+			fields = append(fields, fmt.Sprintf("%s^%g", field, boost))
+		}
+	}
+	return fields
+}
+
+// merchQuery применяет правила мерчендайзинга. Скрытые товары исключаются
+// из самого запроса, а не из post_filter, чтобы не попадать и в фасеты.
+// Закреплённые товары pinned-запрос ставит выше остальных в заданном порядке.
+func merchQuery(query map[string]any, pinned, blocked []int64) map[string]any {
+	if len(pinned) > 0 {
+		query = map[string]any{
+			"pinned": map[string]any{"ids": idStrings(pinned), "organic": query},
+		}
+	}
+	if len(blocked) > 0 {
+		query = map[string]any{
+			"bool": map[string]any{
+				"must":     []map[string]any{query},
+				"must_not": []map[string]any{{"ids": map[string]any{"values": idStrings(blocked)}}},
+			},
+		}
+	}
+	return query
+}
+
+func idStrings(ids []int64) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = strconv.FormatInt(id, 10)
+	}
+	return out
+}
+
+// searchFilters возвращает фильтры по имени фасета, к которому они относятся.
+func searchFilters(params SearchParams) map[string]map[string]any {
+	filters := make(map[string]map[string]any)
+
+	if len(params.Categories) > 0 {
+		filters[facetCategories] = map[string]any{
+			"terms": map[string]any{"category.keyword": params.Categories},
+		}
+	}
+
+	if params.MinPrice != nil || params.MaxPrice != nil {
+		bounds := map[string]any{}
+		if params.MinPrice != nil {
+			bounds["gte"] = *params.MinPrice
+		}
+		if params.MaxPrice != nil {
+			bounds["lte"] = *params.MaxPrice
+		}
+		filters[facetPrices] = map[string]any{"range": map[string]any{"price": bounds}}
+	}
+
+	if params.MinRating != nil {
+		filters[facetRatings] = map[string]any{
+			"range": map[string]any{"avg_rating": map[string]any{"gte": *params.MinRating}},
+		}
+	}
+
+	return filters
+}
+
+// boolFilter объединяет все фильтры, кроме фильтра фасета except.
+func boolFilter(filters map[string]map[string]any, except string) map[string]any {
+	clauses := make([]map[string]any, 0, len(filters))
+	for name, f := range filters {
+		if name != except {
+			clauses = append(clauses, f)
+		}
+	}
+	return map[string]any{"bool": map[string]any{"filter": clauses}}
+}
+
+func facetAgg(filters map[string]map[string]any, facet string, agg map[string]any) map[string]any {
+	return map[string]any{
+		"filter": boolFilter(filters, facet),
+		"aggs":   map[string]any{"values": agg},
+	}
+}
+
+func ratingRanges() []map[string]any {
+	ranges := make([]map[string]any, len(ratingSteps))
+	for i, step := range ratingSteps {
+		ranges[i] = map[string]any{"key": fmt.Sprintf("%d", step), "from": step}
+	}
+	return ranges
+}
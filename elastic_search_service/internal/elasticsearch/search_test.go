@@ -0,0 +1,224 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+const emptySearchResponse = `{"took": 1, "hits": {"total": {"value": 0}, "hits": []}}`
+
+// fakeES отвечает на запросы по пути из responses и запоминает последнее
+// тело запроса для каждого пути. На остальные пути отвечает 404.
+type fakeES struct {
+	mu     sync.Mutex
+	bodies map[string]map[string]any
+}
+
+func newFakeES(t *testing.T, responses map[string]string) (*ESClient, *fakeES) {
+	t.Helper()
+
+	fake := &fakeES{bodies: make(map[string]map[string]any)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &body); err != nil {
+				t.Errorf("%s: invalid request body %s", r.URL.Path, raw)
+			}
+		}
+		fake.mu.Lock()
+		fake.bodies[r.URL.Path] = body
+		fake.mu.Unlock()
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			response = `{}`
+		}
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{server.URL}, DisableRetry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ESClient{Client: client}, fake
+}
+
+func (f *fakeES) body(path string) map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.bodies[path]
+}
+
+// assertJSON сравнивает got с want после сериализации в JSON, чтобы числа и
+// вложенные map сравнивались одинаково.
+func assertJSON(t *testing.T, got any, want string) {
+	t.Helper()
+
+	raw, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotValue, wantValue any
+	if err := json.Unmarshal(raw, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON: %v", err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("expected %s, got %s", want, raw)
+	}
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+func TestSearchFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		params SearchParams
+		want   string
+	}{
+		{
+			name:   "no filters",
+			params: SearchParams{},
+			want:   `{}`,
+		},
+		{
+			name:   "categories",
+			params: SearchParams{Categories: []string{"audio", "phones"}},
+			want:   `{"categories": {"terms": {"category.keyword": ["audio", "phones"]}}}`,
+		},
+		{
+			name:   "open price range",
+			params: SearchParams{MinPrice: ptr(100)},
+			want:   `{"prices": {"range": {"price": {"gte": 100}}}}`,
+		},
+		{
+			name:   "price and rating",
+			params: SearchParams{MinPrice: ptr(100), MaxPrice: ptr(500), MinRating: ptr(4)},
+			want: `{"prices": {"range": {"price": {"gte": 100, "lte": 500}}},
+				"ratings": {"range": {"avg_rating": {"gte": 4}}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertJSON(t, searchFilters(tt.params), tt.want)
+		})
+	}
+}
+
+func TestFacetAggExcludesOwnFilter(t *testing.T) {
+	filters := searchFilters(SearchParams{Categories: []string{"audio"}, MinRating: ptr(4)})
+
+	tests := []struct {
+		facet string
+		want  string
+	}{
+		{facetCategories, `{"bool": {"filter": [{"range": {"avg_rating": {"gte": 4}}}]}}`},
+		{facetRatings, `{"bool": {"filter": [{"terms": {"category.keyword": ["audio"]}}]}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.facet, func(t *testing.T) {
+			agg := facetAgg(filters, tt.facet, map[string]any{"terms": map[string]any{"field": "x"}})
+			assertJSON(t, agg["filter"], tt.want)
+		})
+	}
+
+	if got := boolFilter(filters, "")["bool"].(map[string]any)["filter"].([]map[string]any); len(got) != 2 {
+		t.Errorf("expected post_filter with both filters, got %v", got)
+	}
+}
+
+func TestSearchProductsPostFilter(t *testing.T) {
+	tests := []struct {
+		name           string
+		params         SearchParams
+		wantPostFilter bool
+	}{
+		{"without filters", SearchParams{}, false},
+		{"with category", SearchParams{Categories: []string{"audio"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es, fake := newFakeES(t, map[string]string{"/products/_search": emptySearchResponse})
+
+			if _, err := es.SearchProducts(tt.params); err != nil {
+				t.Fatal(err)
+			}
+
+			body := fake.body("/products/_search")
+			if _, ok := body["post_filter"]; ok != tt.wantPostFilter {
+				t.Errorf("expected post_filter %v, got body %v", tt.wantPostFilter, body)
+			}
+			// фильтры не должны попадать в query, иначе фасеты посчитаются
+			// только по выбранным значениям
+			assertJSON(t, body["query"], `{"match_all": {}}`)
+		})
+	}
+}
+
+func TestMerchQuery(t *testing.T) {
+	organic := map[string]any{"match_all": map[string]any{}}
+
+	tests := []struct {
+		name    string
+		pinned  []int64
+		blocked []int64
+		want    string
+	}{
+		{"no rules", nil, nil, `{"match_all": {}}`},
+		{"pinned", []int64{7, 3}, nil, `{"pinned": {"ids": ["7", "3"], "organic": {"match_all": {}}}}`},
+		{"blocked", nil, []int64{9}, `{"bool": {"must": [{"match_all": {}}], "must_not": [{"ids": {"values": ["9"]}}]}}`},
+		{
+			name:    "pinned and blocked",
+			pinned:  []int64{7},
+			blocked: []int64{9},
+			want: `{"bool": {
+				"must": [{"pinned": {"ids": ["7"], "organic": {"match_all": {}}}}],
+				"must_not": [{"ids": {"values": ["9"]}}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertJSON(t, merchQuery(organic, tt.pinned, tt.blocked), tt.want)
+		})
+	}
+}
+
+func TestBoostedFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		boosts map[string]float64
+		want   []string
+	}{
+		{"defaults", nil, []string{"name^3", "description^2", "category^1"}},
+		{"zero weight drops field", map[string]float64{"name": 1.5, "description": 0}, []string{"name^1.5"}},
+		{"unknown field ignored", map[string]float64{"sku": 10, "category": 2}, []string{"category^2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := boostedFields(tt.boosts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
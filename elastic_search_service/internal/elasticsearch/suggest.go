@@ -0,0 +1,203 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"online-shop/internal/models"
+	"strings"
+)
+
+// didYouMeanBelow - если поиск нашёл меньше товаров, к ответу добавляются
+// исправления запроса.
+const didYouMeanBelow = 3
+
+// maxSuggestSuffixes ограничивает число входов completion-подсказки на товар.
+const maxSuggestSuffixes = 3
+
+// productDoc - документ индекса: товар и входы completion-подсказки.
+type productDoc struct {
+	models.Product
+	Suggest suggestInput `json:"suggest"`
+}
+
+type suggestInput struct {
+	Input  []string `json:"input"`
+	Weight int64    `json:"weight"`
+}
+
+// ProductDoc готовит товар к индексации. Completion-суггестер ищет только
+// по началу входа, поэтому кроме полного названия добавляются его хвосты с
+// каждого слова: «WH-1000XM5» подскажет и «Sony WH-1000XM5».
+func ProductDoc(p models.Product) any {
+	words := strings.Fields(p.Name)
+	inputs := make([]string, 0, maxSuggestSuffixes+1)
+	if len(words) > 0 {
+		inputs = append(inputs, strings.Join(words, " "))
+	}
+	for i := 1; i < len(words) && i <= maxSuggestSuffixes; i++ {
+		inputs = append(inputs, strings.Join(words[i:], " "))
+	}
+
+	return productDoc{
+		Product: p,
+		Suggest: suggestInput{Input: inputs, Weight: p.ReviewCount},
+	}
+}
+
+type Suggestion struct {
+	ID       int    `json:"id"`
+	Text     string `json:"text"`
+	Category string `json:"category"`
+}
+
+type SuggestResult struct {
+	Suggestions []Suggestion `json:"suggestions"`
+	DidYouMean  []string     `json:"did_you_mean,omitempty"`
+}
+
+// Suggest возвращает подсказки по началу ввода. Если заданы категории,
+// подсказки берутся только из них. Когда подсказок мало, к ответу
+// добавляются исправления опечаток.
+func (es *ESClient) Suggest(prefix string, categories []string, size int) (*SuggestResult, error) {
+	completion := map[string]any{
+		"field":           "suggest",
+		"size":            size,
+		"skip_duplicates": true,
+		"fuzzy":           map[string]any{"fuzziness": "AUTO"},
+	}
+	if len(categories) > 0 {
+		completion["contexts"] = map[string]any{"category": categories}
+	}
+
+	searchBody := map[string]any{
+		"_source": []string{"id", "name", "category"},
+		"suggest": map[string]any{
+			"products": map[string]any{
+				"prefix":     prefix,
+				"completion": completion,
+			},
+		},
+	}
+
+	var response struct {
+		Suggest struct {
+			Products []struct {
+				Options []struct {
+					Source models.Product `json:"_source"`
+				} `json:"options"`
+			} `json:"products"`
+		} `json:"suggest"`
+	}
+	if err := es.search(searchBody, &response); err != nil {
+		return nil, err
+	}
+
+	result := &SuggestResult{Suggestions: []Suggestion{}}
+	for _, entry := range response.Suggest.Products {
+		for _, option := range entry.Options {
+			result.Suggestions = append(result.Suggestions, Suggestion{
+				ID:       option.Source.ID,
+				Text:     option.Source.Name,
+				Category: option.Source.Category,
+			})
+		}
+	}
+
+	if len(result.Suggestions) < didYouMeanBelow {
+		corrections, err := es.DidYouMean(prefix)
+		if err != nil {
+			return nil, err
+		}
+		result.DidYouMean = corrections
+	}
+	return result, nil
+}
+
+// DidYouMean предлагает исправления запроса phrase-суггестером по
+// названиям товаров. collate отбрасывает исправления, по которым ничего не
+// найдётся.
+func (es *ESClient) DidYouMean(text string) ([]string, error) {
+	searchBody := map[string]any{
+		"size": 0,
+		"suggest": map[string]any{
+			"text": text,
+			"did_you_mean": map[string]any{
+				"phrase": map[string]any{
+					"field":      "name.shingle",
+					"size":       3,
+					"gram_size":  3,
+					"max_errors": 2,
+					"direct_generator": []map[string]any{
+						{"field": "name.shingle", "suggest_mode": "always"},
+					},
+					"collate": map[string]any{
+						"query": map[string]any{
+							"source": map[string]any{
+								"match": map[string]any{"name": "{{suggestion}}"},
+							},
+						},
+						"prune": false,
+					},
+				},
+			},
+		},
+	}
+
+	var response struct {
+		Suggest struct {
+			DidYouMean []struct {
+				Options []struct {
+					Text string `json:"text"`
+				} `json:"options"`
+			} `json:"did_you_mean"`
+		} `json:"suggest"`
+	}
+	if err := es.search(searchBody, &response); err != nil {
+		return nil, err
+	}
+
+	var corrections []string
+	for _, entry := range response.Suggest.DidYouMean {
+		for _, option := range entry.Options {
+			if option.Text != strings.ToLower(text) {
+				corrections = append(corrections, option.Text)
+			}
+		}
+	}
+	return corrections, nil
+}
+
+// search выполняет запрос к алиасу products и декодирует ответ в out.
+func (es *ESClient) search(searchBody map[string]any, out any) error {
+	return es.searchIndex(productsAlias, searchBody, out)
+}
+
+func (es *ESClient) searchIndex(index string, searchBody map[string]any, out any) error {
+	body, err := json.Marshal(searchBody)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации поискового запроса: %w", err)
+	}
+
+	res, err := es.Client.Search(
+		es.Client.Search.WithIndex(index),
+		es.Client.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения поискового запроса: %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения ответа от Elasticsearch: %w", err)
+	}
+	if res.IsError() {
+		return fmt.Errorf("ошибка поиска в Elasticsearch: %s %s", res.Status(), resBody)
+	}
+	if err := json.Unmarshal(resBody, out); err != nil {
+		return fmt.Errorf("ошибка декодирования ответа от Elasticsearch: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package kafka
+
+import (
+	"log"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+func newConsumerGroup(brokers []string, groupID string) (sarama.ConsumerGroup, error) {
+	config := sarama.NewConfig()
+	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+
+	return sarama.NewConsumerGroup(brokers, groupID, config)
+}
+
+// applyRetryDelay - пауза перед повторным чтением пачки, которую не удалось
+// применить, чтобы недоступный Elasticsearch не вызывал непрерывные
+// ребалансы.
+const applyRetryDelay = 5 * time.Second
+
+// batchHandler читает партицию пачками и передаёт их в apply. apply сам
+// подтверждает сообщения. Если apply вернул ошибку, сессия завершается, и
+// после повторного подключения чтение продолжается с последнего
+// подтверждённого сообщения.
+type batchHandler struct {
+	batchSize    int
+	batchTimeout time.Duration
+	apply        func(session sarama.ConsumerGroupSession, batch []*sarama.ConsumerMessage) error
+}
+
+func (h *batchHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
+func (h *batchHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
+
+// ConsumeClaim копит сообщения в пачку и передаёт её в apply, когда пачка
+// заполнена или с первого сообщения прошло batchTimeout.
+func (h *batchHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	batch := make([]*sarama.ConsumerMessage, 0, h.batchSize)
+	timer := time.NewTimer(h.batchTimeout)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return h.flush(session, batch)
+			}
+			if len(batch) == 0 {
+				timer.Reset(h.batchTimeout)
+			}
+			batch = append(batch, message)
+			if len(batch) < h.batchSize {
+				continue
+			}
+		case <-timer.C:
+		case <-session.Context().Done():
+			// неподтверждённые сообщения будут прочитаны заново после ребаланса
+			return nil
+		}
+
+		timer.Stop()
+		if err := h.flush(session, batch); err != nil {
+			return err
+		}
+		batch = batch[:0]
+	}
+}
+
+func (h *batchHandler) flush(session sarama.ConsumerGroupSession, batch []*sarama.ConsumerMessage) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := h.apply(session, batch)
+	if err != nil {
+		log.Printf("Error applying batch of %d messages, retrying from the last committed offset: %v", len(batch), err)
+		select {
+		case <-time.After(applyRetryDelay):
+		case <-session.Context().Done():
+		}
+	}
+	return err
+}
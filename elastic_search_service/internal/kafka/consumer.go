@@ -2,51 +2,64 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"online-shop/internal/elasticsearch"
 	"online-shop/internal/models"
+	"online-shop/internal/ratings"
+	"online-shop/internal/repository"
+	"strconv"
+	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/Riter/E-Shop/common/events"
 )
 
 type Consumer struct {
-	consumer sarama.ConsumerGroup
-	elastic  *elasticsearch.ESClient
-	topic    string
+	consumer   sarama.ConsumerGroup
+	elastic    *elasticsearch.ESClient
+	ratings    *ratings.Client
+	popularity *repository.PopularityRepo
+	topic      string
+
+	batchSize    int
+	batchTimeout time.Duration
 }
 
-type ProductEvent struct {
-	OperationType int             `json:"operation_type"` 
-	ItemID        int             `json:"item_id"`
-	Item          *models.Product `json:"item"`
-}
-
-func NewConsumer(brokers []string, groupID string, topic string, elastic *elasticsearch.ESClient) (*Consumer, error) {
-	config := sarama.NewConfig()
-	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
-	config.Consumer.Offsets.Initial = sarama.OffsetOldest
-
-	consumer, err := sarama.NewConsumerGroup(brokers, groupID, config)
+func NewConsumer(brokers []string, groupID string, topic string, batchSize int, batchTimeout time.Duration, elastic *elasticsearch.ESClient, ratings *ratings.Client, popularity *repository.PopularityRepo) (*Consumer, error) {
+	consumer, err := newConsumerGroup(brokers, groupID)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Consumer{
-		consumer: consumer,
-		elastic:  elastic,
-		topic:    topic,
+		consumer:   consumer,
+		elastic:    elastic,
+		ratings:    ratings,
+		popularity: popularity,
+		topic:      topic,
+
+		batchSize:    max(batchSize, 1),
+		batchTimeout: batchTimeout,
 	}, nil
 }
 
 func (c *Consumer) Start(ctx context.Context) error {
 	topics := []string{c.topic}
 	handler := &consumerGroupHandler{
-		elastic: c.elastic,
+		elastic:    c.elastic,
+		ratings:    c.ratings,
+		popularity: c.popularity,
+	}
+	batcher := &batchHandler{
+		batchSize:    c.batchSize,
+		batchTimeout: c.batchTimeout,
+		apply:        handler.apply,
 	}
 
 	for {
-		err := c.consumer.Consume(ctx, topics, handler)
+		err := c.consumer.Consume(ctx, topics, batcher)
 		if err != nil {
 			log.Printf("Error from consumer: %v", err)
 			return err
@@ -58,56 +71,85 @@ func (c *Consumer) Start(ctx context.Context) error {
 }
 
 type consumerGroupHandler struct {
-	elastic *elasticsearch.ESClient
+	elastic    *elasticsearch.ESClient
+	ratings    *ratings.Client
+	popularity *repository.PopularityRepo
 }
 
-func (h *consumerGroupHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
-func (h *consumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
-
-func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for message := range claim.Messages() {
-		var event ProductEvent
-		if err := json.Unmarshal(message.Value, &event); err != nil {
-			log.Printf("Error unmarshaling message: %v", err)
+// apply отправляет изменения из пачки в Elasticsearch. Операции над одним
+// товаром сохраняют порядок сообщений. Документы, которые не удалось
+// обработать, логируются, и пачка подтверждается целиком. Если запрос к
+// Elasticsearch не выполнен вовсе, пачка не подтверждается и будет прочитана
+// заново.
+func (h *consumerGroupHandler) apply(session sarama.ConsumerGroupSession, batch []*sarama.ConsumerMessage) error {
+	var (
+		items    = make([]elasticsearch.BulkItem, 0, len(batch))
+		products []models.Product
+		docs     []int
+	)
+	for _, message := range batch {
+		event, err := events.Unmarshal(message.Value, contentType(message))
+		if err != nil {
+			log.Printf("Error decoding product event: %v", err)
 			continue
 		}
 
-		switch event.OperationType {
-		case 3: 
-			if event.Item == nil {
-				log.Printf("Error: CREATE event has nil item")
-				continue
-			}
-			if err := h.elastic.IndexProducts([]models.Product{*event.Item}); err != nil {
-				log.Printf("Error indexing product: %v", err)
-				continue
-			}
-			log.Printf("Product %d indexed in Elasticsearch", event.ItemID)
-
-		case 2: 
-			if event.Item == nil {
-				log.Printf("Error: CHANGE event has nil item")
-				continue
-			}
-			if err := h.elastic.IndexProducts([]models.Product{*event.Item}); err != nil {
-				log.Printf("Error updating product: %v", err)
-				continue
-			}
-			log.Printf("Product %d updated in Elasticsearch", event.ItemID)
-
-		case 1: 
-			if err := h.elastic.DeleteProduct(event.ItemID); err != nil {
-				log.Printf("Error deleting product: %v", err)
-				continue
-			}
-			log.Printf("Product %d deleted from Elasticsearch", event.ItemID)
-
-		default:
-			log.Printf("Unknown operation type: %d", event.OperationType)
-			continue
+		id := strconv.FormatInt(event.ItemID, 10)
+		switch event.Operation {
+		case events.OpCreate, events.OpChange:
+			products = append(products, toProduct(event.Item))
+			docs = append(docs, len(items))
+			items = append(items, elasticsearch.BulkItem{Action: elasticsearch.BulkIndex, ID: id})
+		case events.OpDelete:
+			items = append(items, elasticsearch.BulkItem{Action: elasticsearch.BulkDelete, ID: id})
 		}
+	}
+
+	if err := h.ratings.Enrich(products); err != nil {
+		log.Printf("Error fetching product ratings: %v", err)
+	}
+	if err := h.popularity.Enrich(products); err != nil {
+		log.Printf("Error fetching product popularity: %v", err)
+	}
+	for i, idx := range docs {
+		items[idx].Doc = elasticsearch.ProductDoc(products[i])
+	}
 
-		session.MarkMessage(message, "")
+	err := h.elastic.Bulk(items)
+	var bulkErr *elasticsearch.BulkError
+	switch {
+	case errors.As(err, &bulkErr):
+		log.Printf("Error applying product events: %v", err)
+	case err != nil:
+		return fmt.Errorf("apply %d product events: %w", len(items), err)
+	default:
+		log.Printf("Applied %d product events to Elasticsearch", len(items))
 	}
+
+	session.MarkMessage(batch[len(batch)-1], "")
 	return nil
 }
+
+func contentType(message *sarama.ConsumerMessage) string {
+	for _, h := range message.Headers {
+		if h != nil && string(h.Key) == events.HeaderContentType {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// toProduct converts an event item to the indexed model. Validate has
+// already checked that the item is present and its price is a number.
+func toProduct(item *events.Item) models.Product {
+	price, _ := item.Price.Float64()
+	return models.Product{
+		ID:          int(item.ID),
+		Name:        item.Name,
+		Description: item.Description,
+		Price:       price,
+		Category:    item.Category,
+		CreatedAt:   item.CreatedAt,
+		Images:      item.Images,
+	}
+}
@@ -0,0 +1,166 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"online-shop/internal/elasticsearch"
+	"online-shop/internal/models"
+	"online-shop/internal/ratings"
+	"online-shop/internal/repository"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+const eventProductViewed = "product_viewed"
+
+// commentEvent - событие сервиса комментариев. Рейтинг из события не
+// используется: средний рейтинг после изменения запрашивается заново.
+type commentEvent struct {
+	Type      string `json:"type"`
+	ProductID int64  `json:"product_id"`
+}
+
+// productView - событие просмотра товара, которое публикует facade.
+type productView struct {
+	Type string `json:"type"`
+	SKU  int64  `json:"sku"`
+}
+
+// SignalsConsumer обновляет в индексе сигналы ранжирования: avg_rating и
+// review_count по событиям комментариев, popularity по просмотрам.
+type SignalsConsumer struct {
+	consumer sarama.ConsumerGroup
+	handler  *signalsHandler
+	topics   []string
+
+	batchSize    int
+	batchTimeout time.Duration
+}
+
+func NewSignalsConsumer(brokers []string, groupID string, commentsTopic string, viewsTopic string, batchSize int, batchTimeout time.Duration, elastic *elasticsearch.ESClient, ratings *ratings.Client, popularity *repository.PopularityRepo) (*SignalsConsumer, error) {
+	consumer, err := newConsumerGroup(brokers, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignalsConsumer{
+		consumer: consumer,
+		handler: &signalsHandler{
+			elastic:       elastic,
+			ratings:       ratings,
+			popularity:    popularity,
+			commentsTopic: commentsTopic,
+			viewsTopic:    viewsTopic,
+		},
+		topics: []string{commentsTopic, viewsTopic},
+
+		batchSize:    max(batchSize, 1),
+		batchTimeout: batchTimeout,
+	}, nil
+}
+
+func (c *SignalsConsumer) Start(ctx context.Context) error {
+	batcher := &batchHandler{
+		batchSize:    c.batchSize,
+		batchTimeout: c.batchTimeout,
+		apply:        c.handler.apply,
+	}
+
+	for {
+		err := c.consumer.Consume(ctx, c.topics, batcher)
+		if err != nil {
+			log.Printf("Error from signals consumer: %v", err)
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+type signalsHandler struct {
+	elastic       *elasticsearch.ESClient
+	ratings       *ratings.Client
+	popularity    *repository.PopularityRepo
+	commentsTopic string
+	viewsTopic    string
+}
+
+// apply сворачивает пачку в частичные обновления документов: для товаров с
+// новыми комментариями рейтинг перечитывается из сервиса комментариев,
+// просмотры суммируются в БД. Если рейтинги не удалось получить, просмотры -
+// сохранить или Elasticsearch не выполнил запрос, пачка не подтверждается и
+// будет прочитана заново; уже учтённые просмотры при этом не прибавляются
+// повторно. Документы, отклонённые по отдельности, только логируются: их
+// сигналы восстановит Syncer.RefreshSignals.
+func (h *signalsHandler) apply(session sarama.ConsumerGroupSession, batch []*sarama.ConsumerMessage) error {
+	commented := make(map[int64]struct{})
+	var views []repository.View
+	for _, message := range batch {
+		switch message.Topic {
+		case h.commentsTopic:
+			var event commentEvent
+			if err := json.Unmarshal(message.Value, &event); err != nil || event.ProductID <= 0 {
+				log.Printf("Error decoding comment event: %v", err)
+				continue
+			}
+			commented[event.ProductID] = struct{}{}
+		case h.viewsTopic:
+			var view productView
+			if err := json.Unmarshal(message.Value, &view); err != nil || view.Type != eventProductViewed || view.SKU <= 0 {
+				continue
+			}
+			views = append(views, repository.View{Offset: message.Offset, ProductID: view.SKU})
+		}
+	}
+
+	var items []elasticsearch.BulkItem
+	if len(commented) > 0 {
+		products := make([]models.Product, 0, len(commented))
+		for id := range commented {
+			products = append(products, models.Product{ID: int(id)})
+		}
+		if err := h.ratings.Enrich(products); err != nil {
+			return fmt.Errorf("fetch product ratings: %w", err)
+		}
+		for _, p := range products {
+			items = append(items, elasticsearch.BulkItem{
+				Action: elasticsearch.BulkUpdate,
+				ID:     strconv.Itoa(p.ID),
+				Doc:    map[string]any{"avg_rating": p.AvgRating, "review_count": p.ReviewCount},
+			})
+		}
+	}
+	if len(views) > 0 {
+		// пачка состоит из сообщений одной партиции
+		last := batch[len(batch)-1]
+		totals, err := h.popularity.AddViews(last.Topic, last.Partition, last.Offset, views)
+		if err != nil {
+			return fmt.Errorf("save product views: %w", err)
+		}
+		for id, total := range totals {
+			items = append(items, elasticsearch.BulkItem{
+				Action: elasticsearch.BulkUpdate,
+				ID:     strconv.FormatInt(id, 10),
+				Doc:    map[string]any{"popularity": total},
+			})
+		}
+	}
+
+	err := h.elastic.Bulk(items)
+	var bulkErr *elasticsearch.BulkError
+	switch {
+	case errors.As(err, &bulkErr):
+		log.Printf("Error applying ranking signals: %v", err)
+	case err != nil:
+		return fmt.Errorf("apply %d ranking signals: %w", len(items), err)
+	}
+
+	session.MarkMessage(batch[len(batch)-1], "")
+	return nil
+}
@@ -0,0 +1,21 @@
+package models
+
+// Synonym - правило синонимов в формате Solr.
+type Synonym struct {
+	ID   int    `json:"id"`
+	Rule string `json:"rule"`
+}
+
+// SearchRule закрепляет и скрывает товары в выдаче по конкретному запросу.
+type SearchRule struct {
+	Query   string  `json:"query"`
+	Pinned  []int64 `json:"pinned"`
+	Blocked []int64 `json:"blocked"`
+}
+
+// BoostProfile задаёт веса полей для текстового поиска.
+type BoostProfile struct {
+	Name    string             `json:"name"`
+	Fields  map[string]float64 `json:"fields"`
+	Default bool               `json:"default"`
+}
@@ -13,4 +13,7 @@ type Product struct {
 	Category    string    `json:"category"`
 	CreatedAt   time.Time `json:"created_at"`
 	Images      []string  `json:"images"`
+	AvgRating   float64   `json:"avg_rating"`
+	ReviewCount int64     `json:"review_count"`
+	Popularity  int64     `json:"popularity"`
 }
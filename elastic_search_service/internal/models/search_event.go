@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+const (
+	SearchEventSearch = "search"
+	SearchEventClick  = "click"
+)
+
+// SearchEvent - запись журнала поиска: сам поиск или клик по его результату.
+// Клик связан с поиском через SearchID; поля, не относящиеся к типу
+// события, остаются пустыми.
+type SearchEvent struct {
+	Type      string    `json:"type"`
+	SearchID  string    `json:"search_id"`
+	RequestID string    `json:"request_id,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	Query     string    `json:"query"`
+	Timestamp time.Time `json:"@timestamp"`
+
+	Filters   *SearchFilters `json:"filters,omitempty"`
+	Sort      string         `json:"sort,omitempty"`
+	FirstPage bool           `json:"first_page,omitempty"`
+	Hits      int64          `json:"hits"`
+	TookMs    int64          `json:"took_ms,omitempty"`
+	LatencyMs int64          `json:"latency_ms,omitempty"`
+
+	ProductID int `json:"product_id,omitempty"`
+	Position  int `json:"position,omitempty"`
+}
+
+type SearchFilters struct {
+	Categories []string `json:"categories,omitempty"`
+	MinPrice   *float64 `json:"min_price,omitempty"`
+	MaxPrice   *float64 `json:"max_price,omitempty"`
+	MinRating  *float64 `json:"min_rating,omitempty"`
+}
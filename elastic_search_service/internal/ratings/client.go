@@ -0,0 +1,81 @@
+package ratings
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"online-shop/config"
+	"online-shop/internal/models"
+	"strconv"
+	"strings"
+)
+
+type rating struct {
+	ProductID     int64   `json:"product_id"`
+	AverageRating float64 `json:"average_rating"`
+	ReviewCount   int64   `json:"review_count"`
+}
+
+// Client получает рейтинги товаров из сервиса комментариев.
+type Client struct {
+	baseURL   string
+	batchSize int
+	http      *http.Client
+}
+
+func NewClient(cfg *config.CommentsConfig) *Client {
+	return &Client{
+		baseURL:   strings.TrimRight(cfg.URL, "/"),
+		batchSize: max(cfg.BatchSize, 1),
+		http:      &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Enrich заполняет AvgRating и ReviewCount у товаров, запрашивая рейтинги
+// пачками. При ошибке часть товаров может остаться без рейтинга.
+func (c *Client) Enrich(products []models.Product) error {
+	for start := 0; start < len(products); start += c.batchSize {
+		batch := products[start:min(start+c.batchSize, len(products))]
+
+		ids := make([]string, len(batch))
+		for i, p := range batch {
+			ids[i] = strconv.Itoa(p.ID)
+		}
+
+		ratings, err := c.fetch(ids)
+		if err != nil {
+			return err
+		}
+		for i := range batch {
+			if r, ok := ratings[int64(batch[i].ID)]; ok {
+				batch[i].AvgRating = r.AverageRating
+				batch[i].ReviewCount = r.ReviewCount
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Client) fetch(ids []string) (map[int64]rating, error) {
+	res, err := c.http.Get(c.baseURL + "/ratings?product_ids=" + url.QueryEscape(strings.Join(ids, ",")))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса рейтингов: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка запроса рейтингов: %s", res.Status)
+	}
+
+	var list []rating
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования рейтингов: %w", err)
+	}
+
+	result := make(map[int64]rating, len(list))
+	for _, r := range list {
+		result[r.ProductID] = r
+	}
+	return result, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"online-shop/internal/models"
+
+	"github.com/lib/pq"
+)
+
+var ErrNotFound = errors.New("запись не найдена")
+
+// MerchRepo хранит настройки поиска: синонимы, правила для запросов и
+// профили весов полей.
+type MerchRepo struct {
+	PsqlDb *sql.DB
+}
+
+func NewMerchRepo(PsqlDb *sql.DB) *MerchRepo {
+	return &MerchRepo{PsqlDb: PsqlDb}
+}
+
+func (r *MerchRepo) ListSynonyms() ([]models.Synonym, error) {
+	rows, err := r.PsqlDb.Query("SELECT id, rule FROM search_synonyms ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	synonyms := []models.Synonym{}
+	for rows.Next() {
+		var s models.Synonym
+		if err := rows.Scan(&s.ID, &s.Rule); err != nil {
+			return nil, err
+		}
+		synonyms = append(synonyms, s)
+	}
+	return synonyms, rows.Err()
+}
+
+func (r *MerchRepo) CreateSynonym(rule string) (int, error) {
+	var id int
+	err := r.PsqlDb.QueryRow("INSERT INTO search_synonyms (rule) VALUES ($1) RETURNING id", rule).Scan(&id)
+	return id, err
+}
+
+func (r *MerchRepo) DeleteSynonym(id int) error {
+	return expectAffected(r.PsqlDb.Exec("DELETE FROM search_synonyms WHERE id = $1", id))
+}
+
+func (r *MerchRepo) ListRules() ([]models.SearchRule, error) {
+	rows, err := r.PsqlDb.Query("SELECT query, pinned, blocked FROM search_rules ORDER BY query")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.SearchRule{}
+	for rows.Next() {
+		var rule models.SearchRule
+		if err := rows.Scan(&rule.Query, pq.Array(&rule.Pinned), pq.Array(&rule.Blocked)); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *MerchRepo) UpsertRule(rule models.SearchRule) error {
+	_, err := r.PsqlDb.Exec(`
+		INSERT INTO search_rules (query, pinned, blocked, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (query) DO UPDATE
+		SET pinned = EXCLUDED.pinned, blocked = EXCLUDED.blocked, updated_at = NOW()
+	`, rule.Query, pq.Array(rule.Pinned), pq.Array(rule.Blocked))
+	return err
+}
+
+func (r *MerchRepo) DeleteRule(query string) error {
+	return expectAffected(r.PsqlDb.Exec("DELETE FROM search_rules WHERE query = $1", query))
+}
+
+func (r *MerchRepo) ListProfiles() ([]models.BoostProfile, error) {
+	rows, err := r.PsqlDb.Query("SELECT name, fields, is_default FROM search_boost_profiles ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []models.BoostProfile{}
+	for rows.Next() {
+		var p models.BoostProfile
+		var fields []byte
+		if err := rows.Scan(&p.Name, &fields, &p.Default); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(fields, &p.Fields); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, rows.Err()
+}
+
+// UpsertProfile сохраняет профиль. Если он помечен как профиль по
+// умолчанию, флаг снимается с остальных в той же транзакции.
+func (r *MerchRepo) UpsertProfile(p models.BoostProfile) error {
+	fields, err := json.Marshal(p.Fields)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.PsqlDb.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if p.Default {
+		if _, err := tx.Exec("UPDATE search_boost_profiles SET is_default = FALSE WHERE is_default AND name <> $1", p.Name); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO search_boost_profiles (name, fields, is_default, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (name) DO UPDATE
+		SET fields = EXCLUDED.fields, is_default = EXCLUDED.is_default, updated_at = NOW()
+	`, p.Name, fields, p.Default); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *MerchRepo) DeleteProfile(name string) error {
+	return expectAffected(r.PsqlDb.Exec("DELETE FROM search_boost_profiles WHERE name = $1", name))
+}
+
+func expectAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
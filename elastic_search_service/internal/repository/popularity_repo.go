@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"database/sql"
+	"online-shop/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// PopularityRepo хранит число просмотров товаров.
+type PopularityRepo struct {
+	PsqlDb *sql.DB
+}
+
+func NewPopularityRepo(PsqlDb *sql.DB) *PopularityRepo {
+	return &PopularityRepo{PsqlDb: PsqlDb}
+}
+
+// View - просмотр товара из сообщения топика со смещением этого сообщения.
+type View struct {
+	Offset    int64
+	ProductID int64
+}
+
+// AddViews прибавляет просмотры из сообщений одной партиции и возвращает
+// новые значения по каждому товару. lastOffset - смещение последнего
+// прочитанного сообщения партиции. Просмотры из сообщений, которые уже были
+// учтены до повторного чтения, пропускаются.
+func (r *PopularityRepo) AddViews(topic string, partition int32, lastOffset int64, views []View) (map[int64]int64, error) {
+	tx, err := r.PsqlDb.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO product_view_offsets (topic, partition, last_offset)
+		VALUES ($1, $2, -1)
+		ON CONFLICT (topic, partition) DO NOTHING`, topic, partition); err != nil {
+		return nil, err
+	}
+	var applied int64
+	if err := tx.QueryRow(`
+		SELECT last_offset FROM product_view_offsets
+		WHERE topic = $1 AND partition = $2
+		FOR UPDATE`, topic, partition).Scan(&applied); err != nil {
+		return nil, err
+	}
+
+	counts := countViews(views, applied)
+	if len(counts) > 0 {
+		ids := make([]int64, 0, len(counts))
+		n := make([]int64, 0, len(counts))
+		for id, c := range counts {
+			ids = append(ids, id)
+			n = append(n, c)
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO product_popularity (product_id, views)
+			SELECT * FROM unnest($1::int[], $2::bigint[])
+			ON CONFLICT (product_id) DO UPDATE
+			SET views = product_popularity.views + EXCLUDED.views, updated_at = NOW()`, pq.Array(ids), pq.Array(n)); err != nil {
+			return nil, err
+		}
+	}
+	if lastOffset > applied {
+		if _, err := tx.Exec(`
+			UPDATE product_view_offsets SET last_offset = $3
+			WHERE topic = $1 AND partition = $2`, topic, partition, lastOffset); err != nil {
+			return nil, err
+		}
+	}
+
+	// Итоги возвращаются и по уже учтённым просмотрам: их запись в индекс
+	// могла не выполниться в прошлый раз.
+	ids := make([]int64, 0, len(views))
+	for _, v := range views {
+		ids = append(ids, v.ProductID)
+	}
+	rows, err := tx.Query("SELECT product_id, views FROM product_popularity WHERE product_id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[int64]int64, len(counts))
+	for rows.Next() {
+		var id, total int64
+		if err := rows.Scan(&id, &total); err != nil {
+			return nil, err
+		}
+		totals[id] = total
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return totals, tx.Commit()
+}
+
+// countViews считает просмотры по товарам, пропуская сообщения со смещением
+// не больше applied.
+func countViews(views []View, applied int64) map[int64]int64 {
+	counts := make(map[int64]int64)
+	for _, v := range views {
+		if v.Offset > applied {
+			counts[v.ProductID]++
+		}
+	}
+	return counts
+}
+
+// Enrich заполняет Popularity у товаров.
+func (r *PopularityRepo) Enrich(products []models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(products))
+	for i, p := range products {
+		ids[i] = int64(p.ID)
+	}
+
+	rows, err := r.PsqlDb.Query("SELECT product_id, views FROM product_popularity WHERE product_id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	views := make(map[int]int64, len(products))
+	for rows.Next() {
+		var id int
+		var n int64
+		if err := rows.Scan(&id, &n); err != nil {
+			return err
+		}
+		views[id] = n
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range products {
+		products[i].Popularity = views[products[i].ID]
+	}
+	return nil
+}
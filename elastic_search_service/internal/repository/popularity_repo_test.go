@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountViews(t *testing.T) {
+	views := []View{{Offset: 10, ProductID: 1}, {Offset: 11, ProductID: 2}, {Offset: 12, ProductID: 1}, {Offset: 14, ProductID: 3}}
+
+	tests := []struct {
+		name    string
+		applied int64
+		want    map[int64]int64
+	}{
+		{"first read", -1, map[int64]int64{1: 2, 2: 1, 3: 1}},
+		{"redelivered after partial commit", 11, map[int64]int64{1: 1, 3: 1}},
+		{"already counted", 14, map[int64]int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countViews(views, tt.applied); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
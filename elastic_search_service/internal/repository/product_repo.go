@@ -2,9 +2,11 @@ package repository
 
 import (
 	"database/sql"
-	"log"
+	"errors"
 	"online-shop/internal/models"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/minio/minio-go/v7"
 )
 
@@ -64,27 +66,180 @@ func (r *ProductRepo) getProductImages(productID int) ([]string, error) {
 	return images, nil
 }
 
-func (r *ProductRepo) GetALLProducts() ([]models.Product, error) {
+// Cursor - позиция keyset-пагинации: строки упорядочены по (время, ID).
+type Cursor struct {
+	At time.Time
+	ID int
+}
+
+// ProductsAfter возвращает до limit товаров, изменённых после cursor, вместе
+// с картинками, и позицию последнего из них.
+func (r *ProductRepo) ProductsAfter(cursor Cursor, limit int) ([]models.Product, Cursor, error) {
+	rows, err := r.PsqlDb.Query(`
+		SELECT p.id, p.name, COALESCE(p.description, ''), p.price, p.category,
+			COALESCE(p.created_at, p.updated_at), p.updated_at,
+			COALESCE(array_agg(i.image_url ORDER BY i.id) FILTER (WHERE i.id IS NOT NULL), '{}')
+		FROM products p
+		LEFT JOIN product_images i ON i.product_id = p.id
+		WHERE (p.updated_at, p.id) > ($1, $2)
+		GROUP BY p.id
+		ORDER BY p.updated_at, p.id
+		LIMIT $3
+	`, cursor.At, cursor.ID, limit)
+	if err != nil {
+		return nil, cursor, err
+	}
+	defer rows.Close()
+
+	products := make([]models.Product, 0, limit)
+	for rows.Next() {
+		var p models.Product
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Category, &p.CreatedAt, &cursor.At, pq.Array(&p.Images)); err != nil {
+			return nil, cursor, err
+		}
+		cursor.ID = p.ID
+		products = append(products, p)
+	}
+	return products, cursor, rows.Err()
+}
+
+// TombstonesAfter возвращает до limit удалённых товаров после cursor.
+func (r *ProductRepo) TombstonesAfter(cursor Cursor, limit int) ([]int, Cursor, error) {
+	rows, err := r.PsqlDb.Query(`
+		SELECT product_id, deleted_at
+		FROM product_tombstones
+		WHERE (deleted_at, product_id) > ($1, $2)
+		ORDER BY deleted_at, product_id
+		LIMIT $3
+	`, cursor.At, cursor.ID, limit)
+	if err != nil {
+		return nil, cursor, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0, limit)
+	for rows.Next() {
+		if err := rows.Scan(&cursor.ID, &cursor.At); err != nil {
+			return nil, cursor, err
+		}
+		ids = append(ids, cursor.ID)
+	}
+	return ids, cursor, rows.Err()
+}
+
+// PurgeTombstones удаляет записи об удалении старше before.
+func (r *ProductRepo) PurgeTombstones(before time.Time) (int64, error) {
+	res, err := r.PsqlDb.Exec("DELETE FROM product_tombstones WHERE deleted_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Checkpoint возвращает сохранённую отметку синхронизации name; нулевое
+// время, если синхронизации ещё не было.
+func (r *ProductRepo) Checkpoint(name string) (time.Time, error) {
+	var watermark time.Time
+	err := r.PsqlDb.QueryRow("SELECT watermark FROM search_sync_checkpoints WHERE name = $1", name).Scan(&watermark)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return watermark, err
+}
 
-	rows, err := r.PsqlDb.Query("SELECT id, name, description, price, category, created_at FROM products")
+// SaveCheckpoint сохраняет отметку. Отметка не сдвигается назад, если другая
+// реплика уже ушла дальше.
+func (r *ProductRepo) SaveCheckpoint(name string, watermark time.Time) error {
+	_, err := r.PsqlDb.Exec(`
+		INSERT INTO search_sync_checkpoints (name, watermark) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE
+		SET watermark = GREATEST(search_sync_checkpoints.watermark, EXCLUDED.watermark), updated_at = NOW()
+	`, name, watermark)
+	return err
+}
+
+// ProductsByIDs возвращает товары с картинками по ID. Удалённых товаров в
+// результате нет.
+func (r *ProductRepo) ProductsByIDs(ids []int) ([]models.Product, error) {
+	rows, err := r.PsqlDb.Query(`
+		SELECT p.id, p.name, COALESCE(p.description, ''), p.price, p.category,
+			COALESCE(p.created_at, p.updated_at),
+			COALESCE(array_agg(i.image_url ORDER BY i.id) FILTER (WHERE i.id IS NOT NULL), '{}')
+		FROM products p
+		LEFT JOIN product_images i ON i.product_id = p.id
+		WHERE p.id = ANY($1)
+		GROUP BY p.id
+		ORDER BY p.id
+	`, pq.Array(toInt64s(ids)))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var products []models.Product
+	products := make([]models.Product, 0, len(ids))
 	for rows.Next() {
 		var p models.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Category, &p.CreatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Category, &p.CreatedAt, pq.Array(&p.Images)); err != nil {
 			return nil, err
 		}
 		products = append(products, p)
 	}
+	return products, rows.Err()
+}
 
-	if err := rows.Err(); err != nil {
+// Retries возвращает до limit документов синхронизации name, которые ждут
+// повтора и ещё не исчерпали maxAttempts попыток.
+func (r *ProductRepo) Retries(name string, maxAttempts, limit int) ([]int, error) {
+	rows, err := r.PsqlDb.Query(`
+		SELECT item_id FROM search_sync_retries
+		WHERE name = $1 AND attempts < $2
+		ORDER BY updated_at, item_id
+		LIMIT $3
+	`, name, maxAttempts, limit)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	log.Printf("Загружено %d товаров из PostgreSQL", len(products))
-	return products, nil
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// AddRetries записывает отклонённые документы; для уже записанных
+// увеличивает число попыток.
+func (r *ProductRepo) AddRetries(name string, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.PsqlDb.Exec(`
+		INSERT INTO search_sync_retries (name, item_id)
+		SELECT $1, unnest($2::int[])
+		ON CONFLICT (name, item_id) DO UPDATE
+		SET attempts = search_sync_retries.attempts + 1, updated_at = NOW()
+	`, name, pq.Array(toInt64s(ids)))
+	return err
+}
+
+// DeleteRetries убирает документы, которые Elasticsearch принял.
+func (r *ProductRepo) DeleteRetries(name string, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.PsqlDb.Exec("DELETE FROM search_sync_retries WHERE name = $1 AND item_id = ANY($2)", name, pq.Array(toInt64s(ids)))
+	return err
+}
+
+func toInt64s(ids []int) []int64 {
+	result := make([]int64, len(ids))
+	for i, id := range ids {
+		result[i] = int64(id)
+	}
+	return result
 }
@@ -2,76 +2,219 @@ package services
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"online-shop/internal/elasticsearch"
-	"online-shop/internal/repository"
+	"online-shop/internal/models"
+	"strconv"
 	"time"
+
+	"github.com/Riter/E-Shop/common/httperr"
 )
 
 type ElasticManager struct {
-	Repo    *repository.ProductRepo
 	Elastic *elasticsearch.ESClient
+	Merch   *Merchandiser
+	Logger  *SearchLogger
 }
 
 
-func NewElasticManager(repo *repository.ProductRepo, elastic *elasticsearch.ESClient) *ElasticManager {
-	return &ElasticManager{Repo: repo, Elastic: elastic}
+func NewElasticManager(elastic *elasticsearch.ESClient, merch *Merchandiser, logger *SearchLogger) *ElasticManager {
+	return &ElasticManager{Elastic: elastic, Merch: merch, Logger: logger}
 }
 
-
-func (s *ElasticManager) SyncProductsToElasticSearch() error {
-	products, err := s.Repo.GetALLProducts()
+func (s *ElasticManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	params, err := parseSearchParams(r.URL.Query())
 	if err != nil {
-		log.Println("ошибка из функции получения товаров: %w", err)
-		return err
+		httperr.Write(w, r, invalidParam(err))
+		return
+	}
+	if err := s.Merch.Apply(&params, r.URL.Query().Get("profile")); err != nil {
+		httperr.Write(w, r, httperr.BadRequest("unknown_profile", err.Error()))
+		return
 	}
 
-	err = s.Elastic.IndexProducts(products)
+	result, err := s.Elastic.SearchProducts(params)
+	if errors.Is(err, elasticsearch.ErrInvalidSort) || errors.Is(err, elasticsearch.ErrInvalidPageToken) || errors.Is(err, elasticsearch.ErrResultWindow) {
+		httperr.Write(w, r, invalidParam(err))
+		return
+	}
 	if err != nil {
-		log.Println("ошибка из функции индексации продуктов: %w", err)
-		return err
+		httperr.Write(w, r, fmt.Errorf("ошибка поиска товаров: %w", err))
+		return
 	}
 
-	log.Println("Все товары успешно загружены в Elasticsearch")
-	return nil
+	result.SearchID = newEventID()
+	s.Logger.Log(models.SearchEvent{
+		Type:      models.SearchEventSearch,
+		SearchID:  result.SearchID,
+		RequestID: httperr.RequestID(r.Context()),
+		UserID:    r.Header.Get(UserIDHeader),
+		Query:     normalizeQuery(params.Query),
+		Timestamp: start.UTC(),
+		Filters: &models.SearchFilters{
+			Categories: params.Categories,
+			MinPrice:   params.MinPrice,
+			MaxPrice:   params.MaxPrice,
+			MinRating:  params.MinRating,
+		},
+		Sort:      params.Sort,
+		FirstPage: params.From == 0 && params.PageToken == "",
+		Hits:      result.Total,
+		TookMs:    result.TookMs,
+		LatencyMs: time.Since(start).Milliseconds(),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
 }
 
+// Suggest обрабатывает /search/suggest: подсказки по мере ввода с
+// необязательным фильтром по категориям.
+func (s *ElasticManager) Suggest(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix := q.Get("q")
+	if prefix == "" {
+		httperr.Write(w, r, httperr.BadRequest("missing_query", "параметр 'q' обязателен"))
+		return
+	}
+	size, err := parseIntParam(q, "size", 5)
+	if err != nil || size < 1 || size > maxSuggestSize {
+		httperr.Write(w, r, invalidParam(fmt.Errorf("параметр 'size' должен быть от 1 до %d", maxSuggestSize)))
+		return
+	}
 
-func (s *ElasticManager) EnablePeriodicSync(minutes uint) {
-	err := s.SyncProductsToElasticSearch()
+	result, err := s.Elastic.Suggest(prefix, q["category"], size)
 	if err != nil {
-		log.Println("Синхронизация товаров с Elasticsearch не удалась %w", err)
+		httperr.Write(w, r, fmt.Errorf("ошибка получения подсказок: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+const maxSuggestSize = 20
+
+// maxPageSize ограничивает size, чтобы один запрос не выгружал весь каталог.
+const maxPageSize = 100
+
+// parseSearchParams читает q, category (можно повторять), min_price,
+// max_price, min_rating, price_interval, параметры пагинации (sort, from,
+// size, deep, page_token) и подсветки.
+func parseSearchParams(q url.Values) (elasticsearch.SearchParams, error) {
+	params := elasticsearch.SearchParams{
+		Query:      q.Get("q"),
+		Categories: q["category"],
+		Sort:       q.Get("sort"),
+		PageToken:  q.Get("page_token"),
+		Deep:       q.Get("deep") == "true",
+	}
+
+	var err error
+	if params.From, err = parseIntParam(q, "from", 0); err != nil {
+		return params, err
+	}
+	if params.Size, err = parseIntParam(q, "size", 10); err != nil {
+		return params, err
+	}
+	if params.From < 0 || params.Size < 1 || params.Size > maxPageSize {
+		return params, fmt.Errorf("параметр 'size' должен быть от 1 до %d, 'from' - неотрицательным", maxPageSize)
+	}
+	if params.From > 0 && (params.Deep || params.PageToken != "") {
+		return params, fmt.Errorf("параметр 'from' нельзя использовать с глубокой пагинацией")
 	}
 
-	ticker := time.NewTicker(time.Duration(minutes) * time.Minute)
+	if params.Highlight, err = parseHighlightParams(q); err != nil {
+		return params, err
+	}
 
-	go func() {
-		defer ticker.Stop()
-		for range ticker.C {
-			log.Println("Запуск плановой синхронизации товаров с Elasticsearch...")
-			err := s.SyncProductsToElasticSearch()
-			if err != nil {
-				log.Println("Ошибка синхронизации товаров с Elasticsearch: %w", err)
-			}
+	if params.MinPrice, err = parseFloatParam(q, "min_price"); err != nil {
+		return params, err
+	}
+	if params.MaxPrice, err = parseFloatParam(q, "max_price"); err != nil {
+		return params, err
+	}
+	if params.MinRating, err = parseFloatParam(q, "min_rating"); err != nil {
+		return params, err
+	}
+	interval, err := parseFloatParam(q, "price_interval")
+	if err != nil {
+		return params, err
+	}
+	if interval != nil {
+		if *interval <= 0 {
+			return params, fmt.Errorf("параметр 'price_interval' должен быть положительным")
 		}
-	}()
+		params.PriceInterval = *interval
+	}
+
+	if params.Query == "" && len(params.Categories) == 0 && params.MinPrice == nil && params.MaxPrice == nil && params.MinRating == nil {
+		return params, fmt.Errorf("нужен параметр 'q' или хотя бы один фильтр")
+	}
+	return params, nil
 }
 
-func (s *ElasticManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
-	if query == "" {
-		http.Error(w, "параметр 'q' обязателен", http.StatusBadRequest)
-		return
+const (
+	defaultFragmentSize = 150
+	maxFragmentSize     = 1000
+	maxHighlightTagLen  = 32
+)
+
+// parseHighlightParams читает highlight, snippet, pre_tag, post_tag и
+// fragment_size. snippet=true включает подсветку и заменяет описание
+// сниппетом.
+func parseHighlightParams(q url.Values) (*elasticsearch.HighlightParams, error) {
+	snippet := q.Get("snippet") == "true"
+	if q.Get("highlight") != "true" && !snippet {
+		return nil, nil
+	}
+
+	hl := &elasticsearch.HighlightParams{
+		PreTag:      q.Get("pre_tag"),
+		PostTag:     q.Get("post_tag"),
+		SnippetOnly: snippet,
+	}
+	if hl.PreTag == "" && hl.PostTag == "" {
+		hl.PreTag, hl.PostTag = "<em>", "</em>"
 	}
+	if len(hl.PreTag) > maxHighlightTagLen || len(hl.PostTag) > maxHighlightTagLen {
+		return nil, fmt.Errorf("теги подсветки должны быть не длиннее %d символов", maxHighlightTagLen)
+	}
+
+	var err error
+	if hl.FragmentSize, err = parseIntParam(q, "fragment_size", defaultFragmentSize); err != nil {
+		return nil, err
+	}
+	if hl.FragmentSize < 1 || hl.FragmentSize > maxFragmentSize {
+		return nil, fmt.Errorf("параметр 'fragment_size' должен быть от 1 до %d", maxFragmentSize)
+	}
+	return hl, nil
+}
 
-	products, err := s.Elastic.SearchProducts(query)
+func parseIntParam(q url.Values, name string, def int) (int, error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
 	if err != nil {
-		log.Println("ошибка поиска товаров %w", err)
-		http.Error(w, "ошибка поиска товаров", http.StatusInternalServerError)
-		return
+		return 0, fmt.Errorf("параметр '%s' должен быть целым числом", name)
 	}
+	return v, nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(products)
+func parseFloatParam(q url.Values, name string) (*float64, error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("параметр '%s' должен быть числом", name)
+	}
+	return &v, nil
 }
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"online-shop/config"
+	"online-shop/internal/elasticsearch"
+	"online-shop/internal/models"
+	"sync/atomic"
+	"time"
+
+	"github.com/Riter/E-Shop/common/httperr"
+	"github.com/go-chi/chi/v5"
+)
+
+// UserIDHeader - ID пользователя, который клиент передаёт сам. Заголовок не
+// аутентифицирован: proxy пока не проверяет JWT и удаляет его из входящих
+// запросов, поэтому user_id в журнале поиска есть только у запросов в обход
+// proxy и годится лишь для аналитики.
+const UserIDHeader = "X-User-ID"
+
+const (
+	defaultAnalyticsPeriod = 7 * 24 * time.Hour
+	maxAnalyticsSize       = 100
+)
+
+// SearchLogger пишет журнал поиска в индексы search-logs-*. Log не
+// блокирует обработку запроса: события копятся в очереди и отправляются
+// пачками из Run, а при переполнении очереди отбрасываются.
+type SearchLogger struct {
+	Elastic *elasticsearch.ESClient
+	cfg     *config.AnalyticsConfig
+
+	queue   chan models.SearchEvent
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+func NewSearchLogger(elastic *elasticsearch.ESClient, cfg *config.AnalyticsConfig) *SearchLogger {
+	return &SearchLogger{
+		Elastic: elastic,
+		cfg:     cfg,
+		queue:   make(chan models.SearchEvent, cfg.QueueSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// Log ставит событие в очередь.
+func (l *SearchLogger) Log(event models.SearchEvent) {
+	select {
+	case l.queue <- event:
+	default:
+		l.dropped.Add(1)
+	}
+}
+
+// Run отправляет события, пока не вызван Close.
+func (l *SearchLogger) Run() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.SearchEvent, 0, l.cfg.FlushSize)
+	for {
+		select {
+		case event, ok := <-l.queue:
+			if !ok {
+				l.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= l.cfg.FlushSize {
+				l.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			l.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// Close отправляет оставшиеся события и дожидается Run.
+func (l *SearchLogger) Close() {
+	close(l.queue)
+	<-l.done
+}
+
+// flush раскладывает события по месячным индексам. Ошибки только
+// логируются: потеря части журнала не должна влиять на поиск.
+func (l *SearchLogger) flush(batch []models.SearchEvent) {
+	if n := l.dropped.Swap(0); n > 0 {
+		log.Printf("журнал поиска: очередь переполнена, отброшено событий: %d", n)
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	byIndex := make(map[string][]elasticsearch.BulkItem)
+	for _, event := range batch {
+		index := elasticsearch.SearchLogIndex(event.Timestamp)
+		byIndex[index] = append(byIndex[index], elasticsearch.BulkItem{
+			Action: elasticsearch.BulkCreate,
+			ID:     newEventID(),
+			Doc:    event,
+		})
+	}
+	for index, items := range byIndex {
+		if err := l.Elastic.BulkTo(index, items); err != nil {
+			log.Printf("журнал поиска: ошибка записи в %s: %v", index, err)
+		}
+	}
+}
+
+type clickRequest struct {
+	SearchID  string `json:"search_id"`
+	Query     string `json:"query"`
+	ProductID int    `json:"product_id"`
+	Position  int    `json:"position"`
+}
+
+// Click обрабатывает /search/click: переход на товар из выдачи. position
+// считается с 1 по всей выдаче, а не внутри страницы.
+func (l *SearchLogger) Click(w http.ResponseWriter, r *http.Request) {
+	var body clickRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		httperr.Write(w, r, errInvalidBody)
+		return
+	}
+	if body.SearchID == "" || body.ProductID <= 0 || body.Position <= 0 {
+		httperr.Write(w, r, httperr.BadRequest("invalid_click", "поля 'search_id', 'product_id' и 'position' обязательны"))
+		return
+	}
+
+	l.Log(models.SearchEvent{
+		Type:      models.SearchEventClick,
+		SearchID:  body.SearchID,
+		RequestID: httperr.RequestID(r.Context()),
+		UserID:    r.Header.Get(UserIDHeader),
+		Query:     normalizeQuery(body.Query),
+		Timestamp: time.Now().UTC(),
+		ProductID: body.ProductID,
+		Position:  body.Position,
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Routes регистрирует /admin/search/analytics/*.
+func (l *SearchLogger) Routes(r chi.Router) {
+	r.Get("/top-queries", l.report(l.Elastic.TopQueries))
+	r.Get("/zero-results", l.report(l.Elastic.ZeroResultQueries))
+	r.Get("/ctr", l.report(l.Elastic.QueryCTR))
+}
+
+func (l *SearchLogger) report(query func(elasticsearch.AnalyticsRange) ([]elasticsearch.QueryStats, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rng, err := parseAnalyticsRange(r)
+		if err != nil {
+			httperr.Write(w, r, invalidParam(err))
+			return
+		}
+
+		stats, err := query(rng)
+		if err != nil {
+			httperr.Write(w, r, fmt.Errorf("журнал поиска: %w", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"from":    rng.From,
+			"to":      rng.To,
+			"queries": stats,
+		})
+	}
+}
+
+// parseAnalyticsRange читает from и to в RFC 3339 или как даты YYYY-MM-DD;
+// по умолчанию отчёт строится за последние 7 дней.
+func parseAnalyticsRange(r *http.Request) (elasticsearch.AnalyticsRange, error) {
+	q := r.URL.Query()
+	rng := elasticsearch.AnalyticsRange{To: time.Now().UTC()}
+
+	var err error
+	if s := q.Get("to"); s != "" {
+		if rng.To, err = parseTimeParam(s); err != nil {
+			return rng, fmt.Errorf("параметр 'to' должен быть датой: %w", err)
+		}
+	}
+	rng.From = rng.To.Add(-defaultAnalyticsPeriod)
+	if s := q.Get("from"); s != "" {
+		if rng.From, err = parseTimeParam(s); err != nil {
+			return rng, fmt.Errorf("параметр 'from' должен быть датой: %w", err)
+		}
+	}
+	if !rng.From.Before(rng.To) {
+		return rng, errors.New("'from' должен быть раньше 'to'")
+	}
+
+	rng.Size, err = parseIntParam(q, "size", 20)
+	if err != nil || rng.Size < 1 || rng.Size > maxAnalyticsSize {
+		return rng, fmt.Errorf("параметр 'size' должен быть от 1 до %d", maxAnalyticsSize)
+	}
+	return rng, nil
+}
+
+func parseTimeParam(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func newEventID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
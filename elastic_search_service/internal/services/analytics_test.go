@@ -0,0 +1,76 @@
+package services
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseAnalyticsRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantFrom time.Time
+		wantTo   time.Time
+		wantSize int
+		wantErr  bool
+	}{
+		{
+			name:     "dates",
+			query:    "from=2025-05-01&to=2025-05-08",
+			wantFrom: time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2025, 5, 8, 0, 0, 0, 0, time.UTC),
+			wantSize: 20,
+		},
+		{
+			name:     "default period ends at to",
+			query:    "to=2025-05-08T12:00:00Z&size=5",
+			wantFrom: time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2025, 5, 8, 12, 0, 0, 0, time.UTC),
+			wantSize: 5,
+		},
+		{
+			name:     "offset timestamps",
+			query:    "from=2025-05-01T03:00:00%2B03:00&to=2025-05-02",
+			wantFrom: time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2025, 5, 2, 0, 0, 0, 0, time.UTC),
+			wantSize: 20,
+		},
+		{name: "from after to", query: "from=2025-05-08&to=2025-05-01", wantErr: true},
+		{name: "empty period", query: "from=2025-05-01&to=2025-05-01", wantErr: true},
+		{name: "bad date", query: "from=01.05.2025", wantErr: true},
+		{name: "size too large", query: "size=101", wantErr: true},
+		{name: "zero size", query: "size=0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng, err := parseAnalyticsRange(httptest.NewRequest("GET", "/admin/search/analytics/top-queries?"+tt.query, nil))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", rng)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !rng.From.Equal(tt.wantFrom) || !rng.To.Equal(tt.wantTo) || rng.Size != tt.wantSize {
+				t.Errorf("expected [%s, %s) size %d, got [%s, %s) size %d", tt.wantFrom, tt.wantTo, tt.wantSize, rng.From, rng.To, rng.Size)
+			}
+		})
+	}
+}
+
+func TestParseAnalyticsRangeDefaultsToLastWeek(t *testing.T) {
+	rng, err := parseAnalyticsRange(httptest.NewRequest("GET", "/admin/search/analytics/top-queries", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rng.To.Sub(rng.From); got != defaultAnalyticsPeriod {
+		t.Errorf("expected a %s period, got %s", defaultAnalyticsPeriod, got)
+	}
+	if time.Since(rng.To) > time.Minute {
+		t.Errorf("expected the period to end now, got %s", rng.To)
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"online-shop/internal/repository"
+
+	"github.com/Riter/E-Shop/common/httperr"
+)
+
+// Ошибки отдаются в общем формате httperr, как и в остальных Go-сервисах.
+var (
+	errInvalidBody    = httperr.BadRequest("invalid_body", "некорректное тело запроса")
+	errReindexRunning = httperr.New(http.StatusConflict, "reindex_running", ErrReindexRunning.Error())
+)
+
+// invalidParam оборачивает ошибку разбора параметров запроса.
+func invalidParam(err error) *httperr.Error {
+	return httperr.BadRequest("invalid_parameter", err.Error())
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, v any, err error) {
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError отвечает 404 на repository.ErrNotFound; остальные ошибки, кроме
+// *httperr.Error, логируются и отдаются как internal_error без текста.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		err = httperr.NotFound("not_found", err.Error())
+	}
+	httperr.Write(w, r, err)
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"online-shop/config"
+	"online-shop/internal/elasticsearch"
+	"online-shop/internal/models"
+	"online-shop/internal/repository"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Riter/E-Shop/common/httperr"
+	"github.com/go-chi/chi/v5"
+)
+
+var ErrUnknownProfile = errors.New("неизвестный профиль весов")
+
+const synonymsHeader = "# Файл генерирует search_service из таблицы search_synonyms, не редактируйте вручную.\n"
+
+// Merchandiser применяет к поиску настройки из PostgreSQL: правила для
+// запросов и профили весов полей держатся в памяти и перечитываются раз в
+// RefreshInterval, так что изменения с других реплик доходят без
+// перезапуска. Синонимы записываются в файл, который читает Elasticsearch.
+type Merchandiser struct {
+	Repo    *repository.MerchRepo
+	Elastic *elasticsearch.ESClient
+	cfg     *config.MerchConfig
+
+	mu             sync.RWMutex
+	rules          map[string]models.SearchRule
+	profiles       map[string]models.BoostProfile
+	defaultProfile string
+}
+
+func NewMerchandiser(repo *repository.MerchRepo, elastic *elasticsearch.ESClient, cfg *config.MerchConfig) *Merchandiser {
+	return &Merchandiser{Repo: repo, Elastic: elastic, cfg: cfg}
+}
+
+// Load перечитывает правила и профили из БД.
+func (m *Merchandiser) Load() error {
+	rules, err := m.Repo.ListRules()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки правил поиска: %w", err)
+	}
+	profiles, err := m.Repo.ListProfiles()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки профилей весов: %w", err)
+	}
+
+	byQuery := make(map[string]models.SearchRule, len(rules))
+	for _, rule := range rules {
+		byQuery[rule.Query] = rule
+	}
+	byName := make(map[string]models.BoostProfile, len(profiles))
+	defaultProfile := ""
+	for _, p := range profiles {
+		byName[p.Name] = p
+		if p.Default {
+			defaultProfile = p.Name
+		}
+	}
+
+	m.mu.Lock()
+	m.rules, m.profiles, m.defaultProfile = byQuery, byName, defaultProfile
+	m.mu.Unlock()
+	return nil
+}
+
+// Watch перечитывает настройки, пока не отменён ctx.
+func (m *Merchandiser) Watch(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Load(); err != nil {
+				log.Printf("мерчендайзинг: %v", err)
+			}
+		}
+	}
+}
+
+// SyncSynonyms записывает синонимы из БД в файл и перезагружает поисковые
+// анализаторы. Файл заменяется атомарно, чтобы Elasticsearch не прочитал
+// его наполовину записанным.
+func (m *Merchandiser) SyncSynonyms() error {
+	synonyms, err := m.Repo.ListSynonyms()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки синонимов: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString(synonymsHeader)
+	for _, s := range synonyms {
+		b.WriteString(s.Rule)
+		b.WriteByte('\n')
+	}
+
+	tmp := m.cfg.SynonymsFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("ошибка записи файла синонимов: %w", err)
+	}
+	if err := os.Rename(tmp, m.cfg.SynonymsFile); err != nil {
+		return fmt.Errorf("ошибка записи файла синонимов: %w", err)
+	}
+
+	return m.Elastic.ReloadSearchAnalyzers()
+}
+
+// Apply дополняет параметры поиска весами профиля и правилами для запроса.
+// Пустой profile означает профиль по умолчанию.
+func (m *Merchandiser) Apply(params *elasticsearch.SearchParams, profile string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if profile == "" {
+		profile = m.defaultProfile
+	}
+	if profile != "" {
+		p, ok := m.profiles[profile]
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownProfile, profile)
+		}
+		params.Boosts = p.Fields
+	}
+
+	if rule, ok := m.rules[normalizeQuery(params.Query)]; ok {
+		params.Pinned = rule.Pinned
+		params.Blocked = rule.Blocked
+	}
+	return nil
+}
+
+// normalizeQuery приводит запрос к виду, в котором хранятся правила.
+func normalizeQuery(q string) string {
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ")
+}
+
+// Routes регистрирует /admin/search/*.
+func (m *Merchandiser) Routes(r chi.Router) {
+	r.Get("/synonyms", m.listSynonyms)
+	r.Post("/synonyms", m.createSynonym)
+	r.Delete("/synonyms/{id}", m.deleteSynonym)
+
+	r.Get("/rules", m.listRules)
+	r.Put("/rules", m.putRule)
+	r.Delete("/rules", m.deleteRule)
+
+	r.Get("/profiles", m.listProfiles)
+	r.Put("/profiles/{name}", m.putProfile)
+	r.Delete("/profiles/{name}", m.deleteProfile)
+}
+
+func (m *Merchandiser) listSynonyms(w http.ResponseWriter, r *http.Request) {
+	synonyms, err := m.Repo.ListSynonyms()
+	writeJSON(w, r, synonyms, err)
+}
+
+func (m *Merchandiser) createSynonym(w http.ResponseWriter, r *http.Request) {
+	var body models.Synonym
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		httperr.Write(w, r, errInvalidBody)
+		return
+	}
+	body.Rule = strings.TrimSpace(body.Rule)
+	if err := validateSynonym(body.Rule); err != nil {
+		httperr.Write(w, r, httperr.BadRequest("invalid_synonym", err.Error()))
+		return
+	}
+
+	id, err := m.Repo.CreateSynonym(body.Rule)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	m.syncSynonyms()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.Synonym{ID: id, Rule: body.Rule})
+}
+
+func (m *Merchandiser) deleteSynonym(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httperr.Write(w, r, httperr.BadRequest("invalid_id", "некорректный id"))
+		return
+	}
+	if err := m.Repo.DeleteSynonym(id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	m.syncSynonyms()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *Merchandiser) listRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := m.Repo.ListRules()
+	writeJSON(w, r, rules, err)
+}
+
+func (m *Merchandiser) putRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.SearchRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		httperr.Write(w, r, errInvalidBody)
+		return
+	}
+	rule.Query = normalizeQuery(rule.Query)
+	if rule.Query == "" {
+		httperr.Write(w, r, httperr.BadRequest("missing_query", "поле 'query' обязательно"))
+		return
+	}
+	if rule.Pinned == nil {
+		rule.Pinned = []int64{}
+	}
+	if rule.Blocked == nil {
+		rule.Blocked = []int64{}
+	}
+	for _, id := range rule.Pinned {
+		if slices.Contains(rule.Blocked, id) {
+			httperr.Write(w, r, httperr.BadRequest("invalid_rule", fmt.Sprintf("товар %d не может быть одновременно закреплён и скрыт", id)))
+			return
+		}
+	}
+
+	if err := m.Repo.UpsertRule(rule); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	m.reload()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *Merchandiser) deleteRule(w http.ResponseWriter, r *http.Request) {
+	if err := m.Repo.DeleteRule(normalizeQuery(r.URL.Query().Get("query"))); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	m.reload()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *Merchandiser) listProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := m.Repo.ListProfiles()
+	writeJSON(w, r, profiles, err)
+}
+
+func (m *Merchandiser) putProfile(w http.ResponseWriter, r *http.Request) {
+	var profile models.BoostProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		httperr.Write(w, r, errInvalidBody)
+		return
+	}
+	profile.Name = chi.URLParam(r, "name")
+	if len(profile.Fields) == 0 {
+		httperr.Write(w, r, httperr.BadRequest("missing_fields", "поле 'fields' обязательно"))
+		return
+	}
+	for field, boost := range profile.Fields {
+		if !slices.Contains(elasticsearch.BoostableFields, field) {
+			httperr.Write(w, r, httperr.BadRequest("invalid_profile", fmt.Sprintf("поле %q нельзя использовать, допустимы: %s", field, strings.Join(elasticsearch.BoostableFields, ", "))))
+			return
+		}
+		if boost < 0 {
+			httperr.Write(w, r, httperr.BadRequest("invalid_profile", fmt.Sprintf("вес поля %q не может быть отрицательным", field)))
+			return
+		}
+	}
+
+	if err := m.Repo.UpsertProfile(profile); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	m.reload()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *Merchandiser) deleteProfile(w http.ResponseWriter, r *http.Request) {
+	if err := m.Repo.DeleteProfile(chi.URLParam(r, "name")); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	m.reload()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reload применяет изменение на этой реплике сразу, не дожидаясь Watch.
+func (m *Merchandiser) reload() {
+	if err := m.Load(); err != nil {
+		log.Printf("мерчендайзинг: %v", err)
+	}
+}
+
+// syncSynonyms не возвращает ошибку клиенту: изменение уже сохранено в БД,
+// а файл будет перезаписан при следующем изменении или перезапуске.
+func (m *Merchandiser) syncSynonyms() {
+	if err := m.SyncSynonyms(); err != nil {
+		log.Printf("мерчендайзинг: не удалось применить синонимы: %v", err)
+	}
+}
+
+// validateSynonym проверяет строку в формате Solr: «a, b, c» или «a, b => c».
+// Elasticsearch не перезагрузит анализаторы с некорректным файлом, поэтому
+// пустые синонимы отклоняются здесь.
+func validateSynonym(rule string) error {
+	if rule == "" || strings.ContainsAny(rule, "\r\n#") {
+		return errors.New("правило должно быть одной непустой строкой без '#'")
+	}
+
+	sides := strings.Split(rule, "=>")
+	switch {
+	case len(sides) > 2:
+		return errors.New("правило может содержать только один '=>'")
+	case len(sides) == 1 && !strings.Contains(rule, ","):
+		return errors.New("правило должно содержать ',' или '=>'")
+	}
+	for _, side := range sides {
+		for _, term := range strings.Split(side, ",") {
+			if strings.TrimSpace(term) == "" {
+				return errors.New("синоним не может быть пустым")
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"online-shop/internal/elasticsearch"
+	"online-shop/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestValidateSynonym(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr bool
+	}{
+		{"ноутбук, лэптоп, laptop", false},
+		{"телек, tv => телевизор", false},
+		{"iphone=>айфон", false},
+		{"", true},
+		{"ноутбук", true},
+		{"ноутбук, лэптоп\nтелек, tv", true},
+		{"ноутбук, лэптоп # комментарий", true},
+		{"ноутбук, , лэптоп", true},
+		{"ноутбук,", true},
+		{"=> телевизор", true},
+		{"телек =>", true},
+		{"a => b => c", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			if err := validateSynonym(tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMerchandiserApply(t *testing.T) {
+	m := &Merchandiser{
+		rules: map[string]models.SearchRule{
+			"iphone 15": {Query: "iphone 15", Pinned: []int64{7, 3}, Blocked: []int64{9}},
+		},
+		profiles: map[string]models.BoostProfile{
+			"default": {Name: "default", Fields: map[string]float64{"name": 5}, Default: true},
+			"catalog": {Name: "catalog", Fields: map[string]float64{"category": 4}},
+		},
+		defaultProfile: "default",
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		profile   string
+		want      elasticsearch.SearchParams
+		wantErr   error
+		noDefault bool
+	}{
+		{
+			name:  "default profile and rule for normalized query",
+			query: "  iPhone   15 ",
+			want: elasticsearch.SearchParams{
+				Query:   "  iPhone   15 ",
+				Boosts:  map[string]float64{"name": 5},
+				Pinned:  []int64{7, 3},
+				Blocked: []int64{9},
+			},
+		},
+		{
+			name:    "explicit profile without rule",
+			query:   "наушники",
+			profile: "catalog",
+			want:    elasticsearch.SearchParams{Query: "наушники", Boosts: map[string]float64{"category": 4}},
+		},
+		{
+			name:    "unknown profile",
+			query:   "наушники",
+			profile: "missing",
+			wantErr: ErrUnknownProfile,
+		},
+		{
+			name:      "no default profile keeps default boosts",
+			query:     "наушники",
+			noDefault: true,
+			want:      elasticsearch.SearchParams{Query: "наушники"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Merchandiser{rules: m.rules, profiles: m.profiles, defaultProfile: m.defaultProfile}
+			if tt.noDefault {
+				m.defaultProfile = ""
+			}
+
+			params := elasticsearch.SearchParams{Query: tt.query}
+			err := m.Apply(&params, tt.profile)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(params, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, params)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"online-shop/internal/elasticsearch"
+	"online-shop/internal/ratings"
+	"online-shop/internal/repository"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/Riter/E-Shop/common/httperr"
+)
+
+var ErrReindexRunning = errors.New("переиндексация уже запущена")
+
+const reindexPageSize = 1000
+
+// Reindexer строит новую версию индекса из PostgreSQL и переключает на неё
+// алиас products. Поиск всё это время работает по старой версии.
+type Reindexer struct {
+	Repo       *repository.ProductRepo
+	Elastic    *elasticsearch.ESClient
+	Ratings    *ratings.Client
+	Popularity *repository.PopularityRepo
+
+	running atomic.Bool
+}
+
+func NewReindexer(repo *repository.ProductRepo, elastic *elasticsearch.ESClient, ratings *ratings.Client, popularity *repository.PopularityRepo) *Reindexer {
+	return &Reindexer{Repo: repo, Elastic: elastic, Ratings: ratings, Popularity: popularity}
+}
+
+// Run выполняет переиндексацию и возвращает ErrReindexRunning, если она уже
+// идёт.
+func (r *Reindexer) Run() error {
+	if !r.running.CompareAndSwap(false, true) {
+		return ErrReindexRunning
+	}
+	defer r.running.Store(false)
+	return r.run()
+}
+
+// Start запускает переиндексацию в фоне.
+func (r *Reindexer) Start() error {
+	if !r.running.CompareAndSwap(false, true) {
+		return ErrReindexRunning
+	}
+
+	go func() {
+		defer r.running.Store(false)
+		if err := r.run(); err != nil {
+			log.Printf("переиндексация не удалась: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (r *Reindexer) run() error {
+	index, err := r.Elastic.CreateVersionedIndex()
+	if err != nil {
+		return err
+	}
+	log.Printf("переиндексация: создан индекс %s", index)
+
+	// Изменения из Kafka пишутся и в новый индекс, пока на него не
+	// переключится алиас.
+	r.Elastic.StartShadow(index)
+	defer r.Elastic.StopShadow()
+
+	old, err := r.fill(index)
+	if err != nil {
+		if derr := r.Elastic.DeleteIndices([]string{index}); derr != nil {
+			log.Printf("переиндексация: не удалось удалить индекс %s: %v", index, derr)
+		}
+		return err
+	}
+
+	if err := r.Elastic.DeleteIndices(old); err != nil {
+		log.Printf("переиндексация: не удалось удалить старые индексы %v: %v", old, err)
+	}
+	log.Printf("переиндексация завершена: алиас указывает на %s", index)
+	return nil
+}
+
+// fill загружает товары в index страницами и переключает на него алиас.
+// Документы создаются через create, чтобы не затереть более свежие
+// изменения из Kafka.
+func (r *Reindexer) fill(index string) ([]string, error) {
+	cursor := repository.Cursor{}
+	for {
+		products, next, err := r.Repo.ProductsAfter(cursor, reindexPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения товаров: %w", err)
+		}
+		if len(products) == 0 {
+			break
+		}
+
+		enrichProducts(r.Ratings, r.Popularity, products)
+		items := make([]elasticsearch.BulkItem, 0, len(products))
+		for _, product := range products {
+			items = append(items, elasticsearch.BulkItem{Action: elasticsearch.BulkCreate, ID: strconv.Itoa(product.ID), Doc: elasticsearch.ProductDoc(product)})
+		}
+		if err := r.Elastic.BulkTo(index, items); err != nil {
+			return nil, fmt.Errorf("ошибка загрузки товаров в %s: %w", index, err)
+		}
+		cursor = next
+	}
+
+	// Товары, удалённые во время загрузки, могли попасть в выборку из БД.
+	var deletes []elasticsearch.BulkItem
+	for _, id := range r.Elastic.ShadowDeletes() {
+		deletes = append(deletes, elasticsearch.BulkItem{Action: elasticsearch.BulkDelete, ID: id})
+	}
+	if err := r.Elastic.BulkTo(index, deletes); err != nil {
+		return nil, fmt.Errorf("ошибка удаления товаров из %s: %w", index, err)
+	}
+
+	return r.Elastic.SwapAlias(index)
+}
+
+// ServeHTTP обрабатывает POST /admin/reindex.
+func (r *Reindexer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := r.Start(); err != nil {
+		httperr.Write(w, req, errReindexRunning)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
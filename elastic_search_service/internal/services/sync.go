@@ -0,0 +1,365 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"online-shop/config"
+	"online-shop/internal/elasticsearch"
+	"online-shop/internal/models"
+	"online-shop/internal/ratings"
+	"online-shop/internal/repository"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	checkpointProducts   = "products"
+	checkpointTombstones = "tombstones"
+)
+
+// syncRepo - методы ProductRepo, которые использует Syncer.
+type syncRepo interface {
+	ProductsAfter(cursor repository.Cursor, limit int) ([]models.Product, repository.Cursor, error)
+	TombstonesAfter(cursor repository.Cursor, limit int) ([]int, repository.Cursor, error)
+	PurgeTombstones(before time.Time) (int64, error)
+	Checkpoint(name string) (time.Time, error)
+	SaveCheckpoint(name string, watermark time.Time) error
+	ProductsByIDs(ids []int) ([]models.Product, error)
+	Retries(name string, maxAttempts, limit int) ([]int, error)
+	AddRetries(name string, ids []int) error
+	DeleteRetries(name string, ids []int) error
+}
+
+type bulkWriter interface {
+	Bulk(items []elasticsearch.BulkItem) error
+}
+
+// enricher дополняет товары сигналами ранжирования: это ratings.Client и
+// repository.PopularityRepo.
+type enricher interface {
+	Enrich(products []models.Product) error
+}
+
+// Syncer переносит в Elasticsearch изменения из PostgreSQL: товары с
+// updated_at после сохранённой отметки и удаления из product_tombstones.
+// Товары читаются страницами по PageSize, отметка сохраняется после каждой
+// страницы, поэтому после перезапуска синхронизация продолжается с места
+// остановки. Первый проход без отметки загружает все товары. Документы,
+// которые Elasticsearch отклонил по отдельности, не задерживают отметку: они
+// записываются в search_sync_retries и повторяются следующими проходами.
+//
+// Кроме того, Syncer периодически перечитывает рейтинги и популярность всех
+// товаров: так в индекс попадают сигналы, события о которых потерялись.
+type Syncer struct {
+	repo       syncRepo
+	elastic    bulkWriter
+	ratings    enricher
+	popularity enricher
+	cfg        *config.SyncConfig
+
+	mu        sync.Mutex
+	signalsMu sync.Mutex
+}
+
+func NewSyncer(repo *repository.ProductRepo, elastic *elasticsearch.ESClient, ratings *ratings.Client, popularity *repository.PopularityRepo, cfg *config.SyncConfig) *Syncer {
+	return &Syncer{repo: repo, elastic: elastic, ratings: ratings, popularity: popularity, cfg: cfg}
+}
+
+// Run выполняет один проход синхронизации. Если предыдущий проход ещё идёт,
+// Run сразу возвращается.
+func (s *Syncer) Run() error {
+	if !s.mu.TryLock() {
+		return nil
+	}
+	defer s.mu.Unlock()
+
+	indexed, err := s.syncProducts()
+	if err != nil {
+		return err
+	}
+	deleted, err := s.syncTombstones()
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{checkpointProducts, checkpointTombstones} {
+		if err := s.retryRejected(name); err != nil {
+			return err
+		}
+	}
+	if indexed > 0 || deleted > 0 {
+		log.Printf("Синхронизация с PostgreSQL: обновлено товаров %d, удалено %d", indexed, deleted)
+	}
+
+	if _, err := s.repo.PurgeTombstones(time.Now().UTC().Add(-s.cfg.TombstoneTTL)); err != nil {
+		log.Printf("не удалось очистить product_tombstones: %v", err)
+	}
+	return nil
+}
+
+// Watch запускает Run раз в Interval и RefreshSignals раз в SignalsInterval,
+// пока не отменён ctx.
+func (s *Syncer) Watch(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	var signals <-chan time.Time
+	if s.cfg.SignalsInterval > 0 {
+		signalsTicker := time.NewTicker(s.cfg.SignalsInterval)
+		defer signalsTicker.Stop()
+		signals = signalsTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Run(); err != nil {
+				log.Printf("Ошибка синхронизации с PostgreSQL: %v", err)
+			}
+		case <-signals:
+			// обновление долгое, поэтому не задерживает синхронизацию
+			go func() {
+				if err := s.RefreshSignals(); err != nil {
+					log.Printf("Ошибка обновления сигналов ранжирования: %v", err)
+				}
+			}()
+		}
+	}
+}
+
+// RefreshSignals перечитывает рейтинги и популярность всех товаров и
+// обновляет в индексе только эти поля. Если сигналы страницы получить не
+// удалось, обновление прерывается: иначе в индекс попали бы нулевые значения.
+// Если предыдущее обновление ещё идёт, RefreshSignals сразу возвращается.
+func (s *Syncer) RefreshSignals() error {
+	if !s.signalsMu.TryLock() {
+		return nil
+	}
+	defer s.signalsMu.Unlock()
+
+	cursor := repository.Cursor{}
+	for {
+		products, next, err := s.repo.ProductsAfter(cursor, s.cfg.PageSize)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения товаров: %w", err)
+		}
+		if len(products) == 0 {
+			return nil
+		}
+
+		if err := s.ratings.Enrich(products); err != nil {
+			return fmt.Errorf("не удалось получить рейтинги товаров: %w", err)
+		}
+		if err := s.popularity.Enrich(products); err != nil {
+			return fmt.Errorf("не удалось получить популярность товаров: %w", err)
+		}
+		items := make([]elasticsearch.BulkItem, 0, len(products))
+		for _, p := range products {
+			items = append(items, elasticsearch.BulkItem{
+				Action: elasticsearch.BulkUpdate,
+				ID:     strconv.Itoa(p.ID),
+				Doc:    map[string]any{"avg_rating": p.AvgRating, "review_count": p.ReviewCount, "popularity": p.Popularity},
+			})
+		}
+		// отклонённые обновления повторит следующее обновление
+		if _, err := s.bulk(items); err != nil {
+			return err
+		}
+		cursor = next
+	}
+}
+
+func (s *Syncer) syncProducts() (int, error) {
+	cursor, err := s.startCursor(checkpointProducts)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for {
+		products, next, err := s.repo.ProductsAfter(cursor, s.cfg.PageSize)
+		if err != nil {
+			return total, fmt.Errorf("ошибка чтения изменённых товаров: %w", err)
+		}
+		if len(products) == 0 {
+			return total, nil
+		}
+
+		enrichProducts(s.ratings, s.popularity, products)
+		items := make([]elasticsearch.BulkItem, 0, len(products))
+		for _, product := range products {
+			items = append(items, elasticsearch.BulkItem{Action: elasticsearch.BulkIndex, ID: strconv.Itoa(product.ID), Doc: elasticsearch.ProductDoc(product)})
+		}
+		rejected, err := s.bulk(items)
+		if err != nil {
+			return total, err
+		}
+		if err := s.repo.AddRetries(checkpointProducts, rejected); err != nil {
+			return total, fmt.Errorf("ошибка сохранения отклонённых документов: %w", err)
+		}
+
+		if err := s.repo.SaveCheckpoint(checkpointProducts, next.At); err != nil {
+			return total, fmt.Errorf("ошибка сохранения отметки синхронизации: %w", err)
+		}
+		total += len(products)
+		cursor = next
+	}
+}
+
+func (s *Syncer) syncTombstones() (int, error) {
+	cursor, err := s.startCursor(checkpointTombstones)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for {
+		ids, next, err := s.repo.TombstonesAfter(cursor, s.cfg.PageSize)
+		if err != nil {
+			return total, fmt.Errorf("ошибка чтения удалённых товаров: %w", err)
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		items := make([]elasticsearch.BulkItem, 0, len(ids))
+		for _, id := range ids {
+			items = append(items, elasticsearch.BulkItem{Action: elasticsearch.BulkDelete, ID: strconv.Itoa(id)})
+		}
+		rejected, err := s.bulk(items)
+		if err != nil {
+			return total, err
+		}
+		if err := s.repo.AddRetries(checkpointTombstones, rejected); err != nil {
+			return total, fmt.Errorf("ошибка сохранения отклонённых документов: %w", err)
+		}
+
+		if err := s.repo.SaveCheckpoint(checkpointTombstones, next.At); err != nil {
+			return total, fmt.Errorf("ошибка сохранения отметки синхронизации: %w", err)
+		}
+		total += len(ids)
+		cursor = next
+	}
+}
+
+// startCursor отступает от сохранённой отметки на Lag; изменения из этого
+// окна применяются повторно, это безопасно.
+func (s *Syncer) startCursor(name string) (repository.Cursor, error) {
+	watermark, err := s.repo.Checkpoint(name)
+	if err != nil {
+		return repository.Cursor{}, fmt.Errorf("ошибка чтения отметки синхронизации: %w", err)
+	}
+	if watermark.IsZero() {
+		return repository.Cursor{}, nil
+	}
+	return repository.Cursor{At: watermark.Add(-s.cfg.Lag)}, nil
+}
+
+// retryRejected повторяет страницу документов синхронизации name из
+// search_sync_retries. Принятые удаляются из таблицы, у снова отклонённых
+// растёт число попыток. Товары, удалённые после отказа, не переиндексируются:
+// их удалит tombstone.
+func (s *Syncer) retryRejected(name string) error {
+	ids, err := s.repo.Retries(name, s.cfg.MaxRetries, s.cfg.PageSize)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения отклонённых документов: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var items []elasticsearch.BulkItem
+	switch name {
+	case checkpointProducts:
+		products, err := s.repo.ProductsByIDs(ids)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения отклонённых товаров: %w", err)
+		}
+		enrichProducts(s.ratings, s.popularity, products)
+		for _, product := range products {
+			items = append(items, elasticsearch.BulkItem{Action: elasticsearch.BulkIndex, ID: strconv.Itoa(product.ID), Doc: elasticsearch.ProductDoc(product)})
+		}
+	case checkpointTombstones:
+		for _, id := range ids {
+			items = append(items, elasticsearch.BulkItem{Action: elasticsearch.BulkDelete, ID: strconv.Itoa(id)})
+		}
+	}
+
+	// здесь отклонение всех документов не ошибка: у них растёт число попыток
+	rejected, err := s.write(items)
+	if err != nil {
+		return err
+	}
+	again := make(map[int]struct{}, len(rejected))
+	for _, id := range rejected {
+		again[id] = struct{}{}
+	}
+	var accepted []int
+	for _, id := range ids {
+		if _, ok := again[id]; !ok {
+			accepted = append(accepted, id)
+		}
+	}
+	if err := s.repo.DeleteRetries(name, accepted); err != nil {
+		return fmt.Errorf("ошибка удаления повторённых документов: %w", err)
+	}
+	if err := s.repo.AddRetries(name, rejected); err != nil {
+		return fmt.Errorf("ошибка сохранения отклонённых документов: %w", err)
+	}
+	return nil
+}
+
+// bulk останавливает синхронизацию, только если запрос не выполнен или
+// отклонены все документы: тогда отметка не сдвигается и страница будет
+// прочитана снова. Если отклонена часть документов, bulk возвращает их ID,
+// чтобы их можно было повторить, не задерживая отметку.
+func (s *Syncer) bulk(items []elasticsearch.BulkItem) ([]int, error) {
+	rejected, err := s.write(items)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > 0 && len(rejected) >= len(items) {
+		return nil, fmt.Errorf("ошибка записи в Elasticsearch: отклонены все %d документов", len(items))
+	}
+	return rejected, nil
+}
+
+// write выполняет bulk-запрос и возвращает ID документов, которые
+// Elasticsearch отклонил по отдельности; причины уже залогированы
+// индексатором.
+func (s *Syncer) write(items []elasticsearch.BulkItem) ([]int, error) {
+	err := s.elastic.Bulk(items)
+	var bulkErr *elasticsearch.BulkError
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.As(err, &bulkErr) {
+		return nil, fmt.Errorf("ошибка записи в Elasticsearch: %w", err)
+	}
+
+	rejected := make([]int, 0, len(bulkErr.Items))
+	for _, item := range bulkErr.Items {
+		id, err := strconv.Atoi(item.ID)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный ID документа %q: %w", item.ID, err)
+		}
+		rejected = append(rejected, id)
+	}
+	return rejected, nil
+}
+
+// enrichProducts дополняет товары рейтингом и популярностью. Ошибки только
+// логируются: товар лучше проиндексировать без сигналов ранжирования, чем не
+// проиндексировать вовсе.
+func enrichProducts(ratings, popularity enricher, products []models.Product) {
+	if err := ratings.Enrich(products); err != nil {
+		log.Printf("не удалось получить рейтинги товаров: %v", err)
+	}
+	if err := popularity.Enrich(products); err != nil {
+		log.Printf("не удалось получить популярность товаров: %v", err)
+	}
+}
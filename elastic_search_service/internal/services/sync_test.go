@@ -0,0 +1,477 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"online-shop/config"
+	"online-shop/internal/elasticsearch"
+	"online-shop/internal/models"
+	"online-shop/internal/repository"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+var syncBase = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+type syncRow struct {
+	id int
+	at time.Time
+}
+
+// fakeSyncRepo повторяет keyset-выборку, GREATEST-отметки и таблицу
+// повторов ProductRepo в памяти и запоминает курсоры, с которых читались
+// товары.
+type fakeSyncRepo struct {
+	products    []syncRow
+	tombstones  []syncRow
+	checkpoints map[string]time.Time
+	retries     map[string]map[int]int
+	cursors     []repository.Cursor
+}
+
+func newFakeSyncRepo() *fakeSyncRepo {
+	return &fakeSyncRepo{
+		checkpoints: make(map[string]time.Time),
+		retries:     map[string]map[int]int{checkpointProducts: {}, checkpointTombstones: {}},
+	}
+}
+
+func rowsAfter(rows []syncRow, cursor repository.Cursor, limit int) ([]syncRow, repository.Cursor) {
+	sorted := append([]syncRow(nil), rows...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].at.Equal(sorted[j].at) {
+			return sorted[i].at.Before(sorted[j].at)
+		}
+		return sorted[i].id < sorted[j].id
+	})
+
+	var page []syncRow
+	for _, row := range sorted {
+		if len(page) == limit {
+			break
+		}
+		if row.at.After(cursor.At) || (row.at.Equal(cursor.At) && row.id > cursor.ID) {
+			page = append(page, row)
+			cursor = repository.Cursor{At: row.at, ID: row.id}
+		}
+	}
+	return page, cursor
+}
+
+func (r *fakeSyncRepo) ProductsAfter(cursor repository.Cursor, limit int) ([]models.Product, repository.Cursor, error) {
+	r.cursors = append(r.cursors, cursor)
+	rows, next := rowsAfter(r.products, cursor, limit)
+	products := make([]models.Product, len(rows))
+	for i, row := range rows {
+		products[i] = models.Product{ID: row.id}
+	}
+	return products, next, nil
+}
+
+func (r *fakeSyncRepo) TombstonesAfter(cursor repository.Cursor, limit int) ([]int, repository.Cursor, error) {
+	rows, next := rowsAfter(r.tombstones, cursor, limit)
+	ids := make([]int, len(rows))
+	for i, row := range rows {
+		ids[i] = row.id
+	}
+	return ids, next, nil
+}
+
+func (r *fakeSyncRepo) PurgeTombstones(time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeSyncRepo) Checkpoint(name string) (time.Time, error) {
+	return r.checkpoints[name], nil
+}
+
+func (r *fakeSyncRepo) SaveCheckpoint(name string, watermark time.Time) error {
+	if watermark.After(r.checkpoints[name]) {
+		r.checkpoints[name] = watermark
+	}
+	return nil
+}
+
+func (r *fakeSyncRepo) ProductsByIDs(ids []int) ([]models.Product, error) {
+	var products []models.Product
+	for _, row := range r.products {
+		for _, id := range ids {
+			if row.id == id {
+				products = append(products, models.Product{ID: id})
+			}
+		}
+	}
+	return products, nil
+}
+
+func (r *fakeSyncRepo) Retries(name string, maxAttempts, limit int) ([]int, error) {
+	var ids []int
+	for id, attempts := range r.retries[name] {
+		if attempts < maxAttempts {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids[:min(limit, len(ids))], nil
+}
+
+func (r *fakeSyncRepo) AddRetries(name string, ids []int) error {
+	for _, id := range ids {
+		r.retries[name][id]++
+	}
+	return nil
+}
+
+func (r *fakeSyncRepo) DeleteRetries(name string, ids []int) error {
+	for _, id := range ids {
+		delete(r.retries[name], id)
+	}
+	return nil
+}
+
+// fakeBulkWriter запоминает выполненные запросы; fail возвращает ошибку
+// запроса по его номеру, начиная с 1.
+type fakeBulkWriter struct {
+	requests [][]elasticsearch.BulkItem
+	fail     func(request int, items []elasticsearch.BulkItem) error
+}
+
+func (w *fakeBulkWriter) Bulk(items []elasticsearch.BulkItem) error {
+	if w.fail != nil {
+		if err := w.fail(len(w.requests)+1, items); err != nil {
+			return err
+		}
+	}
+	w.requests = append(w.requests, items)
+	return nil
+}
+
+// ids возвращает ID документов из запросов, начиная с from.
+func (w *fakeBulkWriter) ids(from int) []string {
+	var ids []string
+	for _, items := range w.requests[from:] {
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+	}
+	return ids
+}
+
+// rejectIDs отклоняет документы ids по отдельности, остальные принимает.
+func rejectIDs(ids ...string) func(int, []elasticsearch.BulkItem) error {
+	return func(_ int, items []elasticsearch.BulkItem) error {
+		var errs []elasticsearch.ItemError
+		for _, item := range items {
+			for _, id := range ids {
+				if item.ID == id {
+					errs = append(errs, elasticsearch.ItemError{Action: item.Action, ID: id, Status: 400})
+				}
+			}
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+		return &elasticsearch.BulkError{Items: errs}
+	}
+}
+
+type fakeEnricher struct {
+	err error
+}
+
+func (e fakeEnricher) Enrich(products []models.Product) error {
+	if e.err != nil {
+		return e.err
+	}
+	for i := range products {
+		products[i].AvgRating = float64(products[i].ID)
+		products[i].ReviewCount = int64(products[i].ID)
+		products[i].Popularity = int64(products[i].ID * 10)
+	}
+	return nil
+}
+
+func newTestSyncer(repo *fakeSyncRepo, elastic *fakeBulkWriter) *Syncer {
+	return &Syncer{
+		repo:       repo,
+		elastic:    elastic,
+		ratings:    fakeEnricher{},
+		popularity: fakeEnricher{},
+		cfg:        &config.SyncConfig{PageSize: 2, Lag: 5 * time.Second, TombstoneTTL: time.Hour, MaxRetries: 3},
+	}
+}
+
+// productsEvery10s возвращает товары 1..n с updated_at через каждые 10 секунд.
+func productsEvery10s(n int) []syncRow {
+	rows := make([]syncRow, n)
+	for i := range rows {
+		rows[i] = syncRow{id: i + 1, at: syncBase.Add(time.Duration(i) * 10 * time.Second)}
+	}
+	return rows
+}
+
+func TestSyncerRestartsFromCheckpoint(t *testing.T) {
+	repo := newFakeSyncRepo()
+	repo.products = productsEvery10s(5)
+	elastic := &fakeBulkWriter{fail: func(request int, _ []elasticsearch.BulkItem) error {
+		if request == 2 {
+			return errors.New("cluster unavailable")
+		}
+		return nil
+	}}
+	syncer := newTestSyncer(repo, elastic)
+
+	// первый проход без отметки читает с начала и останавливается на второй
+	// странице
+	if err := syncer.Run(); err == nil {
+		t.Fatal("expected error from failed page")
+	}
+	if repo.cursors[0] != (repository.Cursor{}) {
+		t.Errorf("expected first run to start from zero cursor, got %+v", repo.cursors[0])
+	}
+	if got := repo.checkpoints[checkpointProducts]; !got.Equal(repo.products[1].at) {
+		t.Fatalf("expected checkpoint after first page %v, got %v", repo.products[1].at, got)
+	}
+
+	// после перезапуска чтение идёт от отметки минус Lag: товар 1 старше
+	// окна, товар 2 в окне и применяется повторно
+	elastic.fail = nil
+	repo.cursors = nil
+	done := len(elastic.requests)
+	if err := syncer.Run(); err != nil {
+		t.Fatal(err)
+	}
+	wantStart := repository.Cursor{At: repo.products[1].at.Add(-5 * time.Second)}
+	if repo.cursors[0] != wantStart {
+		t.Errorf("expected restart from %+v, got %+v", wantStart, repo.cursors[0])
+	}
+	if got, want := elastic.ids(done), []string{"2", "3", "4", "5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected reindexed %v, got %v", want, got)
+	}
+	if got := repo.checkpoints[checkpointProducts]; !got.Equal(repo.products[4].at) {
+		t.Errorf("expected checkpoint %v, got %v", repo.products[4].at, got)
+	}
+}
+
+func TestSyncerLagCatchesLateCommits(t *testing.T) {
+	repo := newFakeSyncRepo()
+	repo.products = productsEvery10s(3)
+	elastic := &fakeBulkWriter{}
+	syncer := newTestSyncer(repo, elastic)
+
+	if err := syncer.Run(); err != nil {
+		t.Fatal(err)
+	}
+	watermark := repo.checkpoints[checkpointProducts]
+
+	// транзакции получили updated_at раньше отметки, но зафиксировались после
+	// прохода: первая попадает в окно Lag, вторая - нет
+	repo.products = append(repo.products,
+		syncRow{id: 10, at: watermark.Add(-3 * time.Second)},
+		syncRow{id: 11, at: watermark.Add(-time.Minute)},
+	)
+	done := len(elastic.requests)
+	if err := syncer.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := elastic.ids(done), []string{"10", "3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected indexed %v, got %v", want, got)
+	}
+	if got := repo.checkpoints[checkpointProducts]; !got.Equal(watermark) {
+		t.Errorf("expected checkpoint to stay at %v, got %v", watermark, got)
+	}
+}
+
+func TestSyncerTombstones(t *testing.T) {
+	tests := []struct {
+		name           string
+		fail           func(request int, items []elasticsearch.BulkItem) error
+		wantErr        bool
+		wantCheckpoint time.Time
+		wantRetries    map[int]int
+	}{
+		{
+			name:           "deleted",
+			wantCheckpoint: syncBase.Add(20 * time.Second),
+		},
+		{
+			name:           "some documents rejected",
+			fail:           rejectIDs("1"),
+			wantCheckpoint: syncBase.Add(20 * time.Second),
+			wantRetries:    map[int]int{1: 1},
+		},
+		{
+			name: "whole page rejected",
+			fail: func(_ int, items []elasticsearch.BulkItem) error {
+				errs := make([]elasticsearch.ItemError, len(items))
+				for i, item := range items {
+					errs[i] = elasticsearch.ItemError{ID: item.ID, Status: 500}
+				}
+				return &elasticsearch.BulkError{Items: errs}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeSyncRepo()
+			repo.tombstones = productsEvery10s(3)
+			elastic := &fakeBulkWriter{fail: tt.fail}
+			syncer := newTestSyncer(repo, elastic)
+
+			_, err := syncer.syncTombstones()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got := repo.checkpoints[checkpointTombstones]; !got.Equal(tt.wantCheckpoint) {
+				t.Errorf("expected checkpoint %v, got %v", tt.wantCheckpoint, got)
+			}
+			// fmt печатает map с сортировкой ключей, а nil и пустую - одинаково
+			if got := repo.retries[checkpointTombstones]; fmt.Sprint(got) != fmt.Sprint(tt.wantRetries) {
+				t.Errorf("expected retries %v, got %v", tt.wantRetries, got)
+			}
+			for _, items := range elastic.requests {
+				for _, item := range items {
+					if item.Action != elasticsearch.BulkDelete {
+						t.Errorf("expected delete, got %s for %s", item.Action, item.ID)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSyncerRefreshSignals(t *testing.T) {
+	repo := newFakeSyncRepo()
+	repo.products = productsEvery10s(3)
+	elastic := &fakeBulkWriter{}
+	syncer := newTestSyncer(repo, elastic)
+
+	if err := syncer.RefreshSignals(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := elastic.ids(0), []string{"1", "2", "3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected refreshed %v, got %v", want, got)
+	}
+	item := elastic.requests[1][0]
+	if item.Action != elasticsearch.BulkUpdate {
+		t.Errorf("expected update, got %s", item.Action)
+	}
+	assertDoc := map[string]any{"avg_rating": 3.0, "review_count": int64(3), "popularity": int64(30)}
+	if !reflect.DeepEqual(item.Doc, assertDoc) {
+		t.Errorf("expected doc %v, got %v", assertDoc, item.Doc)
+	}
+	if len(repo.checkpoints) != 0 {
+		t.Errorf("refresh must not move sync checkpoints, got %v", repo.checkpoints)
+	}
+
+	// без рейтингов обновление прерывается, а не записывает нули
+	syncer.ratings = fakeEnricher{err: errors.New("comments unavailable")}
+	done := len(elastic.requests)
+	if err := syncer.RefreshSignals(); err == nil {
+		t.Fatal("expected error without ratings")
+	}
+	if len(elastic.requests) != done {
+		t.Errorf("expected no writes without ratings, got %v", elastic.ids(done))
+	}
+}
+
+func TestSyncerBulk(t *testing.T) {
+	items := []elasticsearch.BulkItem{{ID: "1"}, {ID: "2"}}
+	rejected := func(ids ...string) error {
+		errs := make([]elasticsearch.ItemError, len(ids))
+		for i, id := range ids {
+			errs[i] = elasticsearch.ItemError{ID: id}
+		}
+		return &elasticsearch.BulkError{Items: errs}
+	}
+
+	tests := []struct {
+		name         string
+		err          error
+		wantRejected []int
+		wantErr      bool
+	}{
+		{"ok", nil, nil, false},
+		{"one document rejected", rejected("1"), []int{1}, false},
+		{"all documents rejected", rejected("1", "2"), nil, true},
+		{"request failed", errors.New("connection refused"), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			syncer := newTestSyncer(newFakeSyncRepo(), &fakeBulkWriter{fail: func(int, []elasticsearch.BulkItem) error { return tt.err }})
+			got, err := syncer.bulk(items)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.wantRejected) {
+				t.Errorf("expected rejected %v, got %v", tt.wantRejected, got)
+			}
+		})
+	}
+}
+
+func TestSyncerRetriesRejectedProducts(t *testing.T) {
+	repo := newFakeSyncRepo()
+	repo.products = productsEvery10s(3)
+	elastic := &fakeBulkWriter{fail: rejectIDs("2")}
+	syncer := newTestSyncer(repo, elastic)
+
+	// отказ по товару 2 не задерживает отметку, товар ждёт повтора
+	if err := syncer.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := repo.checkpoints[checkpointProducts]; !got.Equal(repo.products[2].at) {
+		t.Errorf("expected checkpoint %v, got %v", repo.products[2].at, got)
+	}
+	if got := repo.retries[checkpointProducts]; !reflect.DeepEqual(got, map[int]int{2: 2}) {
+		t.Fatalf("expected product 2 to wait for retry after two attempts, got %v", got)
+	}
+
+	// отметка уже за товаром 2, но следующий проход берёт его из повторов
+	elastic.fail = nil
+	done := len(elastic.requests)
+	if err := syncer.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := elastic.ids(done), []string{"3", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected indexed %v, got %v", want, got)
+	}
+	if got := repo.retries[checkpointProducts]; len(got) != 0 {
+		t.Errorf("expected no retries left, got %v", got)
+	}
+}
+
+func TestSyncerStopsRetryingAfterMaxRetries(t *testing.T) {
+	repo := newFakeSyncRepo()
+	repo.products = productsEvery10s(3)
+	sent := 0
+	reject := rejectIDs("2")
+	elastic := &fakeBulkWriter{fail: func(request int, items []elasticsearch.BulkItem) error {
+		for _, item := range items {
+			if item.ID == "2" {
+				sent++
+			}
+		}
+		return reject(request, items)
+	}}
+	syncer := newTestSyncer(repo, elastic)
+
+	for range 4 {
+		if err := syncer.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// первая запись и два повтора, после MaxRetries попыток товар остаётся в
+	// таблице и больше не отправляется
+	if sent != 3 {
+		t.Errorf("expected product 2 to be sent 3 times, got %d", sent)
+	}
+	if got := repo.retries[checkpointProducts]; !reflect.DeepEqual(got, map[int]int{2: 3}) {
+		t.Errorf("expected product 2 kept after 3 attempts, got %v", got)
+	}
+}
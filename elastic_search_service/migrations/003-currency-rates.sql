@@ -0,0 +1,12 @@
+-- Курсы валют относительно базовой валюты цен в products (PRICE_CURRENCY у facade).
+CREATE TABLE currency_rates (
+    currency CHAR(3) PRIMARY KEY,
+    rate NUMERIC(18,8) NOT NULL CHECK (rate > 0),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO currency_rates (currency, rate) VALUES
+('EUR', 0.92000000),
+('RUB', 81.50000000),
+('JPY', 148.20000000),
+('CHF', 0.89000000);
@@ -0,0 +1,53 @@
+CREATE TABLE product_attributes (
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    key VARCHAR(100) NOT NULL,
+    value TEXT NOT NULL,
+    value_type VARCHAR(10) NOT NULL DEFAULT 'string' CHECK (value_type IN ('string', 'number', 'bool')),
+    PRIMARY KEY (product_id, key)
+);
+
+-- price переопределяет цену товара, NULL означает цену из products.
+CREATE TABLE product_variants (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sku VARCHAR(64) NOT NULL UNIQUE,
+    price DECIMAL(10,2),
+    stock INT NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    attributes JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX idx_product_variants_product_id ON product_variants(product_id);
+
+-- facade слушает канал и сбрасывает кэш товара, вариант которого изменился.
+CREATE FUNCTION notify_product_variant_change() RETURNS trigger AS $$
+DECLARE
+    row RECORD;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row := OLD;
+    ELSE
+        row := NEW;
+    END IF;
+    PERFORM pg_notify('product_variant_changes', json_build_object('product_id', row.product_id, 'sku', row.sku)::text);
+    -- при переносе варианта на другой товар сбрасывается и прежний
+    IF TG_OP = 'UPDATE' AND OLD.product_id <> NEW.product_id THEN
+        PERFORM pg_notify('product_variant_changes', json_build_object('product_id', OLD.product_id, 'sku', OLD.sku)::text);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_variants_notify
+AFTER INSERT OR UPDATE OR DELETE ON product_variants
+FOR EACH ROW EXECUTE FUNCTION notify_product_variant_change();
+
+INSERT INTO product_attributes (product_id, key, value, value_type) VALUES
+(5, 'noise_cancelling', 'true', 'bool'),
+(5, 'battery_hours', '30', 'number'),
+(1, 'storage_gb', '128', 'number');
+
+INSERT INTO product_variants (product_id, sku, price, stock, attributes) VALUES
+(1, 'IPH13-128-BLK', NULL, 12, '{"color": "black", "storage": "128GB"}'),
+(1, 'IPH13-256-BLK', 899.99, 4, '{"color": "black", "storage": "256GB"}'),
+(5, 'WH1000XM5-BLK', NULL, 20, '{"color": "black"}'),
+(5, 'WH1000XM5-SLV', NULL, 0, '{"color": "silver"}');
@@ -0,0 +1,31 @@
+-- Настройки поиска, которые меняют мерчендайзеры через /admin/search/*.
+
+-- Строка в формате синонимов Solr: "телефон, смартфон" или "айфон => iphone".
+CREATE TABLE search_synonyms (
+    id SERIAL PRIMARY KEY,
+    rule TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Правила для конкретного запроса (в нижнем регистре, без лишних пробелов):
+-- pinned поднимаются в начало выдачи в указанном порядке, blocked не
+-- показываются вовсе.
+CREATE TABLE search_rules (
+    query TEXT PRIMARY KEY,
+    pinned INT[] NOT NULL DEFAULT '{}',
+    blocked INT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Веса полей для multi_match, например {"name": 3, "description": 2, "category": 1}.
+CREATE TABLE search_boost_profiles (
+    name VARCHAR(64) PRIMARY KEY,
+    fields JSONB NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_search_boost_profiles_default ON search_boost_profiles(is_default) WHERE is_default;
+
+INSERT INTO search_boost_profiles (name, fields, is_default) VALUES
+('default', '{"name": 3, "description": 2, "category": 1}', TRUE);
@@ -0,0 +1,7 @@
+-- Просмотры товаров из топика product-views. Хранятся здесь, а не только в
+-- индексе, чтобы переиндексация и полная синхронизация их не обнуляли.
+CREATE TABLE product_popularity (
+    product_id INT PRIMARY KEY,
+    views BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
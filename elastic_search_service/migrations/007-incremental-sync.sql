@@ -0,0 +1,65 @@
+-- Инкрементальная синхронизация с Elasticsearch: search_service читает
+-- товары, изменённые после сохранённой отметки, а удалённые - из
+-- product_tombstones. clock_timestamp() вместо NOW(): в долгой транзакции
+-- NOW() вернул бы время её начала, и отметка ушла бы дальше изменения.
+ALTER TABLE products ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT clock_timestamp();
+UPDATE products SET updated_at = COALESCE(created_at, updated_at);
+
+CREATE INDEX idx_products_updated_at ON products(updated_at, id);
+
+CREATE FUNCTION touch_product() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at := clock_timestamp();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_touch
+BEFORE UPDATE ON products
+FOR EACH ROW EXECUTE FUNCTION touch_product();
+
+-- Картинки входят в документ товара, поэтому их изменение тоже двигает
+-- updated_at товара.
+CREATE FUNCTION touch_product_on_image_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE products SET updated_at = clock_timestamp() WHERE id = OLD.product_id;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE products SET updated_at = clock_timestamp() WHERE id = NEW.product_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_images_touch
+AFTER INSERT OR UPDATE OR DELETE ON product_images
+FOR EACH ROW EXECUTE FUNCTION touch_product_on_image_change();
+
+-- Удалённые товары. Строки старше SEARCH_SYNC_TOMBSTONE_TTL удаляет сам
+-- search_service.
+CREATE TABLE product_tombstones (
+    product_id INT PRIMARY KEY,
+    deleted_at TIMESTAMP NOT NULL DEFAULT clock_timestamp()
+);
+
+CREATE INDEX idx_product_tombstones_deleted_at ON product_tombstones(deleted_at, product_id);
+
+CREATE FUNCTION record_product_tombstone() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO product_tombstones (product_id) VALUES (OLD.id)
+    ON CONFLICT (product_id) DO UPDATE SET deleted_at = clock_timestamp();
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_tombstone
+AFTER DELETE ON products
+FOR EACH ROW EXECUTE FUNCTION record_product_tombstone();
+
+-- Отметки синхронизации: name - 'products' или 'tombstones'.
+CREATE TABLE search_sync_checkpoints (
+    name VARCHAR(32) PRIMARY KEY,
+    watermark TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
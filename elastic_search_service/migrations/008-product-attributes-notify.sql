@@ -0,0 +1,23 @@
+-- Атрибуты входят в карточку товара в кэше facade, поэтому их изменения
+-- тоже рассылаются facade, как и изменения вариантов.
+CREATE FUNCTION notify_product_attribute_change() RETURNS trigger AS $$
+DECLARE
+    row RECORD;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row := OLD;
+    ELSE
+        row := NEW;
+    END IF;
+    PERFORM pg_notify('product_attribute_changes', json_build_object('product_id', row.product_id, 'key', row.key)::text);
+    -- при переносе атрибута на другой товар сбрасывается и прежний
+    IF TG_OP = 'UPDATE' AND OLD.product_id <> NEW.product_id THEN
+        PERFORM pg_notify('product_attribute_changes', json_build_object('product_id', OLD.product_id, 'key', OLD.key)::text);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_attributes_notify
+AFTER INSERT OR UPDATE OR DELETE ON product_attributes
+FOR EACH ROW EXECUTE FUNCTION notify_product_attribute_change();
@@ -0,0 +1,9 @@
+-- Смещение последнего сообщения product-views, просмотры из которого уже
+-- прибавлены к product_popularity. Обновляется в той же транзакции, поэтому
+-- сообщения, прочитанные повторно после сбоя, не учитываются дважды.
+CREATE TABLE product_view_offsets (
+    topic TEXT NOT NULL,
+    partition INT NOT NULL,
+    last_offset BIGINT NOT NULL,
+    PRIMARY KEY (topic, partition)
+);
@@ -0,0 +1,12 @@
+-- Документы, которые Elasticsearch отклонил при синхронизации по отдельности.
+-- Отметка синхронизации уходит дальше них, поэтому они повторяются отсюда:
+-- name - 'products' или 'tombstones', как в search_sync_checkpoints. Строки,
+-- исчерпавшие SEARCH_SYNC_MAX_RETRIES попыток, больше не повторяются и
+-- остаются для разбора.
+CREATE TABLE search_sync_retries (
+    name VARCHAR(32) NOT NULL,
+    item_id INT NOT NULL,
+    attempts INT NOT NULL DEFAULT 1,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (name, item_id)
+);
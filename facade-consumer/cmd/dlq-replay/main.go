@@ -0,0 +1,184 @@
+// Command dlq-replay moves messages from the dead-letter topic back to the
+// topic they failed on, e.g. after the bug or outage that broke them is fixed.
+//
+//	go run ./cmd/dlq-replay -brokers kafka:9092 -dlq item-events.dlq -limit 100
+//
+// Replayed messages are committed in a dedicated consumer group, so running
+// the command again continues where the previous run stopped. Each message
+// goes back to the partition it failed on, so it stays ordered with the
+// other events of the same key.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Riter/E-Shop/facade-consumer/internal/consumer"
+	"github.com/segmentio/kafka-go"
+)
+
+func main() {
+	brokers := flag.String("brokers", os.Getenv("KAFKA_BROKERS"), "comma-separated Kafka brokers")
+	dlqTopic := flag.String("dlq", os.Getenv("KAFKA_DLQ_TOPIC"), "dead-letter topic to read from")
+	target := flag.String("to", "", "topic to replay into; defaults to each message's original topic")
+	group := flag.String("group", "", "consumer group; defaults to <dlq>-replay")
+	limit := flag.Int("limit", 0, "maximum number of messages to replay, 0 means all")
+	idle := flag.Duration("idle", 10*time.Second, "stop after this long without new messages")
+	dryRun := flag.Bool("dry-run", false, "print messages without replaying or committing them")
+	flag.Parse()
+
+	if *brokers == "" || *dlqTopic == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *group == "" {
+		*group = *dlqTopic + "-replay"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: strings.Split(*brokers, ","),
+		Topic:   *dlqTopic,
+		GroupID: *group,
+	})
+	defer reader.Close()
+
+	writers := newPartitionWriters(strings.Split(*brokers, ","))
+	defer writers.Close()
+
+	var replayed int
+	for *limit == 0 || replayed < *limit {
+		fetchCtx, cancel := context.WithTimeout(ctx, *idle)
+		m, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				break
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			log.Fatalf("Failed to fetch from %s: %v", *dlqTopic, err)
+		}
+
+		to := *target
+		if to == "" {
+			to = header(m, consumer.HeaderOriginalTopic)
+		}
+		if to == "" {
+			log.Fatalf("Message at offset %d has no %s header, pass -to", m.Offset, consumer.HeaderOriginalTopic)
+		}
+
+		partition := originalPartition(m)
+		log.Printf("offset %d -> %s[%d] (failed %s after %s attempts: %s): %s",
+			m.Offset, to, partition, header(m, consumer.HeaderFailedAt), header(m, consumer.HeaderAttempts),
+			header(m, consumer.HeaderError), string(m.Value))
+		if *dryRun {
+			replayed++
+			continue
+		}
+
+		if err := writers.For(partition).WriteMessages(ctx, kafka.Message{
+			Topic:   to,
+			Key:     m.Key,
+			Value:   m.Value,
+			Headers: originalHeaders(m),
+		}); err != nil {
+			log.Fatalf("Failed to replay offset %d: %v", m.Offset, err)
+		}
+		if err := reader.CommitMessages(ctx, m); err != nil {
+			log.Fatalf("Failed to commit offset %d, it will be replayed again: %v", m.Offset, err)
+		}
+		replayed++
+	}
+
+	log.Printf("Replayed %d messages from %s", replayed, *dlqTopic)
+}
+
+func header(m kafka.Message, key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// originalPartition returns the partition the message failed on, or -1 if
+// the header is missing.
+func originalPartition(m kafka.Message) int {
+	p, err := strconv.Atoi(header(m, consumer.HeaderOriginalPartition))
+	if err != nil || p < 0 {
+		return -1
+	}
+	return p
+}
+
+// partitionBalancer sends every message to one partition. kafka.Writer
+// ignores Message.Partition, so a writer per partition is used instead.
+// Messages for a partition the topic does not have, e.g. when -to names a
+// smaller topic, or without a known partition are hashed by key.
+type partitionBalancer struct {
+	partition int
+	fallback  kafka.Hash
+}
+
+func (b *partitionBalancer) Balance(msg kafka.Message, partitions ...int) int {
+	if slices.Contains(partitions, b.partition) {
+		return b.partition
+	}
+	return b.fallback.Balance(msg, partitions...)
+}
+
+type partitionWriters struct {
+	brokers []string
+	writers map[int]*kafka.Writer
+}
+
+func newPartitionWriters(brokers []string) *partitionWriters {
+	return &partitionWriters{brokers: brokers, writers: make(map[int]*kafka.Writer)}
+}
+
+// For returns the writer for partition, -1 meaning any.
+func (w *partitionWriters) For(partition int) *kafka.Writer {
+	writer, ok := w.writers[partition]
+	if !ok {
+		writer = &kafka.Writer{
+			Addr:         kafka.TCP(w.brokers...),
+			Balancer:     &partitionBalancer{partition: partition},
+			RequiredAcks: kafka.RequireAll,
+		}
+		w.writers[partition] = writer
+	}
+	return writer
+}
+
+func (w *partitionWriters) Close() {
+	for _, writer := range w.writers {
+		writer.Close()
+	}
+}
+
+// originalHeaders drops the headers added when the message was dead-lettered.
+func originalHeaders(m kafka.Message) []kafka.Header {
+	var headers []kafka.Header
+	for _, h := range m.Headers {
+		switch h.Key {
+		case consumer.HeaderError, consumer.HeaderOriginalTopic, consumer.HeaderOriginalPartition,
+			consumer.HeaderOriginalOffset, consumer.HeaderAttempts, consumer.HeaderFailedAt:
+			continue
+		}
+		headers = append(headers, h)
+	}
+	return headers
+}
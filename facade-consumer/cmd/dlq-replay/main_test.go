@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Riter/E-Shop/facade-consumer/internal/consumer"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestOriginalPartition(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []kafka.Header
+		want    int
+	}{
+		{"raw partition", []kafka.Header{{Key: consumer.HeaderOriginalPartition, Value: []byte("0")}}, 0},
+		{"processed partition", []kafka.Header{{Key: consumer.HeaderOriginalPartition, Value: []byte("1")}}, 1},
+		{"missing", nil, -1},
+		{"invalid", []kafka.Header{{Key: consumer.HeaderOriginalPartition, Value: []byte("x")}}, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := originalPartition(kafka.Message{Headers: tt.headers}); got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReplayKeepsOriginalPartition(t *testing.T) {
+	writers := newPartitionWriters([]string{"localhost:9092"})
+	defer writers.Close()
+
+	// Replayed messages have no key, which the old hash balancer spread
+	// round-robin across partitions.
+	for _, partition := range []int{0, 1, 0, 1} {
+		m := kafka.Message{Headers: []kafka.Header{{Key: consumer.HeaderOriginalPartition, Value: []byte{byte('0' + partition)}}}}
+		balancer := writers.For(originalPartition(m)).Balancer
+		if got := balancer.Balance(kafka.Message{Value: []byte("{}")}, 0, 1); got != partition {
+			t.Errorf("expected partition %d, got %d", partition, got)
+		}
+	}
+
+	if writers.For(0) != writers.For(0) {
+		t.Error("expected the writer of a partition to be reused")
+	}
+}
+
+func TestReplayFallsBackToHashForUnknownPartition(t *testing.T) {
+	balancer := &partitionBalancer{partition: 5}
+	msg := kafka.Message{Key: []byte("42")}
+
+	got := balancer.Balance(msg, 0, 1)
+	if want := (&kafka.Hash{}).Balance(msg, 0, 1); got != want {
+		t.Errorf("expected hashed partition %d, got %d", want, got)
+	}
+}
@@ -2,63 +2,102 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"log"
-	"time"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/Riter/E-Shop/common/codec"
+	"github.com/Riter/E-Shop/facade-consumer/internal/cache"
 	"github.com/Riter/E-Shop/facade-consumer/internal/config"
+	"github.com/Riter/E-Shop/facade-consumer/internal/consumer"
+	"github.com/Riter/E-Shop/facade-consumer/internal/invalidation"
 	"github.com/Riter/E-Shop/facade-consumer/internal/kafkaclient"
 	"github.com/Riter/E-Shop/facade-consumer/internal/redisclient"
+	"github.com/Riter/E-Shop/facade-consumer/internal/tracing"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-
-type KafkaMessage struct {
-    OperationType int             `json:"operation_type"`
-    ItemID        int64           `json:"item_id"`
-    Item          json.RawMessage `json:"item"` 
-}
-
 func main() {
-    ctx := context.Background()
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
 
     cfg := config.LoadConfig()
 
+    shutdownTracing := tracing.Init(ctx, cfg.TracingEndpoint)
+
+    metricsMux := http.NewServeMux()
+    metricsMux.Handle("/metrics", promhttp.Handler())
+    metricsSrv := &http.Server{Addr: cfg.MetricsAddr, Handler: metricsMux}
+    go func() {
+        if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            log.Printf("Metrics server failed: %v", err)
+        }
+    }()
+
     rdb := redisclient.NewClient(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
     kafkaReader := kafkaclient.NewReader(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.KafkaGroupID)
+    dlqWriter := kafkaclient.NewWriter(cfg.KafkaBrokers, cfg.DLQTopic)
 
-    log.Printf("Starting Kafka consumer for topic %s\n", cfg.KafkaTopic)
+    cacheCodec, err := codec.ByName(cfg.CacheCodec)
+    if err != nil {
+        log.Fatalf("Invalid CACHE_CODEC value: %v", err)
+    }
 
-    for {
-        m, err := kafkaReader.ReadMessage(ctx)
-        if err != nil {
-            log.Printf("Error reading Kafka message: %v", err)
-            time.Sleep(time.Second)
-            continue
-        }
+    store := cache.NewStore(rdb, cfg.CacheTTL, cfg.VersionTTL)
+    handler := invalidation.NewHandler(rdb, store, invalidation.Options{
+        InvalidationChannel: cfg.InvalidationChannel,
+        Mode:                cfg.CacheMode,
+        VersionSource:       cfg.VersionSource,
+        PriceCurrency:       cfg.PriceCurrency,
+        Codec:               cacheCodec,
+    })
+    c := consumer.New(kafkaReader, dlqWriter, handler.Handle, consumer.Options{
+        Retry: consumer.RetryPolicy{
+            MaxAttempts: cfg.MaxAttempts,
+            Backoff:     cfg.RetryBackoff,
+            MaxBackoff:  cfg.MaxBackoff,
+        },
+        Operation:    invalidation.Operation,
+        BatchHandle:  handler.HandleBatch,
+        Key:          invalidation.Key,
+        BatchSize:    cfg.BatchSize,
+        BatchTimeout: cfg.BatchTimeout,
+        Workers:      cfg.Workers,
+        DrainTimeout: cfg.ShutdownTimeout,
+    })
 
-        log.Printf("Received message at offset %d: %s\n", m.Offset, string(m.Value))
+    log.Printf("Starting Kafka consumer for topic %s in %s mode, dead letters go to %s\n", cfg.KafkaTopic, cfg.CacheMode, cfg.DLQTopic)
 
-        var msg KafkaMessage
-        if err := json.Unmarshal(m.Value, &msg); err != nil {
-            log.Printf("Failed to unmarshal message: %v", err)
-            continue
-        }
+    runErr := c.Run(ctx)
+    if runErr != nil {
+        log.Printf("Consumer stopped: %v", runErr)
+    } else {
+        log.Println("Shutting down")
+    }
+
+    // Run has committed the last batch it finished, so closing the reader
+    // only leaves the consumer group to trigger a quick rebalance.
+    if err := kafkaReader.Close(); err != nil {
+        log.Printf("Error closing Kafka reader: %v", err)
+    }
+    if err := dlqWriter.Close(); err != nil {
+        log.Printf("Error closing dead-letter writer: %v", err)
+    }
+    if err := rdb.Close(); err != nil {
+        log.Printf("Error closing Redis client: %v", err)
+    }
 
-        
-        if msg.OperationType == 1 || msg.OperationType == 2 {
-            key := fmt.Sprintf("%d", msg.ItemID)
-            deleted, err := rdb.Del(ctx, key).Result()
-            if err != nil {
-                log.Printf("Failed to delete key %s from Redis: %v", key, err)
-                continue
-            }
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+    defer cancel()
+    shutdownTracing(shutdownCtx)
+    if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+        log.Printf("Error shutting down metrics server: %v", err)
+    }
 
-            if deleted > 0 {
-                log.Printf("Deleted key %s from Redis", key)
-            } else {
-                log.Printf("Key %s not found in Redis", key)
-            }
-        }
+    if runErr != nil {
+        os.Exit(1)
     }
+    log.Println("Consumer stopped")
 }
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/Riter/E-Shop/common/codec"
+	"github.com/Riter/E-Shop/common/events"
+	"github.com/shopspring/decimal"
+)
+
+// NotFound is the tombstone facade caches for a product missing in Postgres;
+// see models.NotFoundTombstone.
+const NotFound = "__not_found__"
+
+// Money mirrors facade's models.Money.
+type Money struct {
+	Amount   decimal.Decimal `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// Attribute mirrors facade's models.Attribute.
+type Attribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// Variant mirrors facade's models.Variant. InheritsPrice is nil in entries
+// cached before facade started to write it.
+type Variant struct {
+	ID            int               `json:"id"`
+	SKU           string            `json:"sku"`
+	Price         Money             `json:"price"`
+	InheritsPrice *bool             `json:"inherits_price"`
+	Stock         int               `json:"stock"`
+	Attributes    map[string]string `json:"attributes"`
+}
+
+// Product mirrors facade's models.ProductResponse.
+type Product struct {
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       Money       `json:"price"`
+	Category    string      `json:"category"`
+	CreatedAt   time.Time   `json:"created_at"`
+	Images      []string    `json:"images"`
+	Attributes  []Attribute `json:"attributes"`
+	Variants    []Variant   `json:"variants"`
+}
+
+// New converts a created item to facade's cache model; prices are in
+// currency. A new product has no attributes and variants yet: they are added
+// through their own tables, whose triggers make facade drop the entry.
+func New(item events.Item, currency string) (Product, error) {
+	price, err := decimal.NewFromString(item.Price.String())
+	if err != nil {
+		return Product{}, err
+	}
+
+	images := item.Images
+	if images == nil {
+		images = []string{}
+	}
+
+	return Product{
+		ID:          item.ID,
+		Name:        item.Name,
+		Description: item.Description,
+		Price:       Money{Amount: price, Currency: currency},
+		Category:    item.Category,
+		CreatedAt:   item.CreatedAt,
+		Images:      images,
+		Attributes:  []Attribute{},
+		Variants:    []Variant{},
+	}, nil
+}
+
+// Update returns cached with the fields of the changed item. Variants that
+// inherit the product price are repriced. ok is false if the price changed
+// and it is unknown which variants inherit it; the entry can't be updated
+// then.
+func Update(cached Product, item events.Item, currency string) (p Product, ok bool, err error) {
+	p, err = New(item, currency)
+	if err != nil {
+		return Product{}, false, err
+	}
+	p.Attributes = cached.Attributes
+	p.Variants = cached.Variants
+
+	if cached.Price.Currency == currency && cached.Price.Amount.Equal(p.Price.Amount) {
+		return p, true, nil
+	}
+
+	variants := make([]Variant, len(cached.Variants))
+	for i, v := range cached.Variants {
+		if v.InheritsPrice == nil {
+			return Product{}, false, nil
+		}
+		if *v.InheritsPrice {
+			v.Price = p.Price
+		}
+		variants[i] = v
+	}
+	p.Variants = variants
+	return p, true, nil
+}
+
+// Encode returns p in facade's cache format for c.
+func Encode(c codec.Codec, p Product) (string, error) {
+	return codec.Encode(c, p)
+}
+
+// Decode reads a value facade wrote with any of its codecs.
+func Decode(raw string, p *Product) error {
+	return codec.Decode(raw, p)
+}
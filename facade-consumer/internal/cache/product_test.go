@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Riter/E-Shop/common/codec"
+	"github.com/Riter/E-Shop/common/events"
+	"github.com/shopspring/decimal"
+)
+
+func item(t *testing.T, price string) events.Item {
+	t.Helper()
+	var item events.Item
+	if err := json.Unmarshal([]byte(`{"id": 42, "name": "Sony WH-1000XM5", "price": `+price+`,
+		"category": "audio", "created_at": "2025-05-01T12:00:00Z", "images": null}`), &item); err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+func money(amount string) Money {
+	return Money{Amount: decimal.RequireFromString(amount), Currency: "USD"}
+}
+
+func inherits(v bool) *bool {
+	return &v
+}
+
+func TestEncodeUsesFacadeFormat(t *testing.T) {
+	p, err := New(item(t, "399.99"), "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := Encode(codec.JSON{}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw[0] != codec.VersionJSON {
+		t.Fatalf("expected JSON codec byte, got 0x%02x", raw[0])
+	}
+	for _, want := range []string{`"price":{"amount":"399.99","currency":"USD"}`, `"images":[]`, `"attributes":[]`, `"variants":[]`} {
+		if !strings.Contains(raw, want) {
+			t.Errorf("expected %s in %s", want, raw[1:])
+		}
+	}
+}
+
+func TestDecodeFacadeCodecs(t *testing.T) {
+	cached := Product{
+		ID:         42,
+		Price:      money("399.99"),
+		Attributes: []Attribute{{Key: "color", Value: "black", Type: "string"}},
+		Variants:   []Variant{{ID: 1, SKU: "WH-BLK", Price: money("399.99"), InheritsPrice: inherits(true), Stock: 3}},
+	}
+
+	zstd, err := codec.ByName("zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []codec.Codec{codec.JSON{}, codec.Msgpack{}, zstd} {
+		raw, err := Encode(c, cached)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got Product
+		if err := Decode(raw, &got); err != nil {
+			t.Fatalf("codec 0x%02x: %v", c.Version(), err)
+		}
+		if len(got.Variants) != 1 || !got.Variants[0].Price.Amount.Equal(cached.Price.Amount) || got.Attributes[0].Key != "color" {
+			t.Errorf("codec 0x%02x: got %+v", c.Version(), got)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name       string
+		variants   []Variant
+		price      string
+		wantOK     bool
+		wantPrices []string
+	}{
+		{
+			name:       "inherited price follows the product",
+			variants:   []Variant{{SKU: "a", Price: money("10"), InheritsPrice: inherits(true)}, {SKU: "b", Price: money("12"), InheritsPrice: inherits(false)}},
+			price:      "15",
+			wantOK:     true,
+			wantPrices: []string{"15", "12"},
+		},
+		{
+			name:       "override equal to the old price is kept",
+			variants:   []Variant{{SKU: "a", Price: money("10"), InheritsPrice: inherits(false)}},
+			price:      "15",
+			wantOK:     true,
+			wantPrices: []string{"10"},
+		},
+		{
+			name:       "unchanged price keeps legacy variants",
+			variants:   []Variant{{SKU: "a", Price: money("10")}},
+			price:      "10.00",
+			wantOK:     true,
+			wantPrices: []string{"10"},
+		},
+		{
+			name:     "changed price with legacy variants",
+			variants: []Variant{{SKU: "a", Price: money("10")}},
+			price:    "15",
+			wantOK:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cached := Product{ID: 42, Name: "old", Price: money("10"), Attributes: []Attribute{}, Variants: tt.variants}
+
+			got, ok, err := Update(cached, item(t, tt.price), "USD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok %v, got %v", tt.wantOK, ok)
+			}
+			if !ok {
+				return
+			}
+			if got.Name != "Sony WH-1000XM5" {
+				t.Errorf("expected the item's name, got %q", got.Name)
+			}
+			for i, want := range tt.wantPrices {
+				if !got.Variants[i].Price.Amount.Equal(decimal.RequireFromString(want)) {
+					t.Errorf("variant %s: expected price %s, got %s", got.Variants[i].SKU, want, got.Variants[i].Price.Amount)
+				}
+			}
+			if tt.variants[0].Price.Amount.String() != "10" {
+				t.Error("cached variants must not be modified")
+			}
+		})
+	}
+}
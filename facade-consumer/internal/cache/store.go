@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// versionedWrite applies a write only if its version is newer than the one
+// recorded for the key. An empty value deletes the key. The version outlives
+// the value, so a late event cannot resurrect an expired or deleted entry.
+var versionedWrite = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[2]))
+if current and current >= tonumber(ARGV[1]) then
+  return 0
+end
+redis.call('SET', KEYS[2], ARGV[1], 'PX', ARGV[4])
+if ARGV[2] == '' then
+  redis.call('DEL', KEYS[1])
+else
+  redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+end
+return 1
+`)
+
+type Store struct {
+	rdb        *redis.Client
+	ttl        time.Duration
+	versionTTL time.Duration
+}
+
+func NewStore(rdb *redis.Client, ttl, versionTTL time.Duration) *Store {
+	return &Store{rdb: rdb, ttl: ttl, versionTTL: versionTTL}
+}
+
+// VersionKey holds the version of the last event applied to key.
+func VersionKey(key string) string {
+	return key + ":version"
+}
+
+// Get returns the cached value of key, or "" if there is none.
+func (s *Store) Get(ctx context.Context, key string) (string, error) {
+	val, err := s.rdb.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return val, err
+}
+
+// Put stores value under key unless a newer version was applied already.
+// It reports whether the write took place.
+func (s *Store) Put(ctx context.Context, key, value string, version int64) (bool, error) {
+	return s.apply(ctx, key, value, version)
+}
+
+// Delete removes key unless a newer version was applied already.
+func (s *Store) Delete(ctx context.Context, key string, version int64) (bool, error) {
+	return s.apply(ctx, key, "", version)
+}
+
+func (s *Store) apply(ctx context.Context, key, value string, version int64) (bool, error) {
+	applied, err := versionedWrite.Run(ctx, s.rdb, []string{key, VersionKey(key)},
+		version, value, s.ttl.Milliseconds(), s.versionTTL.Milliseconds()).Int()
+	return applied == 1, err
+}
@@ -5,6 +5,7 @@ import (
     "os"
     "strconv"
     "strings"
+    "time"
 )
 
 type Config struct {
@@ -12,9 +13,44 @@ type Config struct {
     KafkaTopic   string
     KafkaGroupID string
 
+    // DLQTopic receives messages that could not be processed, with the error
+    // and original position in headers.
+    DLQTopic string
+
+    MaxAttempts  int
+    RetryBackoff time.Duration
+    MaxBackoff   time.Duration
+
+    // BatchSize messages are fetched at most per round, waiting no longer
+    // than BatchTimeout to fill a batch; Workers handle them in parallel.
+    BatchSize    int
+    BatchTimeout time.Duration
+    Workers      int
+
     RedisAddr     string
     RedisPassword string
     RedisDB       int
+
+    // InvalidationChannel is the Redis pub/sub channel facade replicas listen
+    // on to drop keys from their in-process cache.
+    InvalidationChannel string
+
+    // CacheMode is "invalidate" or "write-through"; see the invalidation
+    // package for what each does.
+    CacheMode     string
+    VersionSource string
+    PriceCurrency string
+    // CacheCodec encodes written entries and must match facade's
+    // CACHE_CODEC; entries in any codec are readable.
+    CacheCodec    string
+    CacheTTL      time.Duration
+    VersionTTL    time.Duration
+
+    MetricsAddr     string
+    TracingEndpoint string
+    // ShutdownTimeout bounds how long the message in flight may take to
+    // finish after SIGTERM.
+    ShutdownTimeout time.Duration
 }
 
 func LoadConfig() *Config {
@@ -27,13 +63,86 @@ func LoadConfig() *Config {
         }
     }
 
+    invalidationChannel := os.Getenv("CACHE_INVALIDATION_CHANNEL")
+    if invalidationChannel == "" {
+        invalidationChannel = "product-invalidations"
+    }
+
+    topic := os.Getenv("KAFKA_TOPIC")
+    dlqTopic := os.Getenv("KAFKA_DLQ_TOPIC")
+    if dlqTopic == "" {
+        dlqTopic = topic + ".dlq"
+    }
+
+    cacheMode := getEnv("CACHE_MODE", "invalidate")
+    if cacheMode != "invalidate" && cacheMode != "write-through" {
+        log.Fatalf("Invalid CACHE_MODE value: %q", cacheMode)
+    }
+    versionSource := getEnv("CACHE_VERSION_SOURCE", "updated_at")
+    if versionSource != "offset" && versionSource != "updated_at" {
+        log.Fatalf("Invalid CACHE_VERSION_SOURCE value: %q", versionSource)
+    }
+
     return &Config{
         KafkaBrokers: strings.Split(os.Getenv("KAFKA_BROKERS"), ","),
-        KafkaTopic:   os.Getenv("KAFKA_TOPIC"),
+        KafkaTopic:   topic,
         KafkaGroupID: os.Getenv("KAFKA_GROUP_ID"),
 
+        DLQTopic:     dlqTopic,
+        MaxAttempts:  getEnvAsInt("CONSUMER_MAX_ATTEMPTS", 5),
+        RetryBackoff: getEnvAsDuration("CONSUMER_RETRY_BACKOFF", 200*time.Millisecond),
+        MaxBackoff:   getEnvAsDuration("CONSUMER_MAX_BACKOFF", 10*time.Second),
+
+        BatchSize:    getEnvAsInt("CONSUMER_BATCH_SIZE", 100),
+        BatchTimeout: getEnvAsDuration("CONSUMER_BATCH_TIMEOUT", 100*time.Millisecond),
+        Workers:      getEnvAsInt("CONSUMER_WORKERS", 4),
+
         RedisAddr:     os.Getenv("REDIS_ADDR"),
         RedisPassword: os.Getenv("REDIS_PASS"),
         RedisDB:       redisDB,
+
+        InvalidationChannel: invalidationChannel,
+
+        CacheMode:     cacheMode,
+        VersionSource: versionSource,
+        PriceCurrency: getEnv("PRICE_CURRENCY", "USD"),
+        CacheCodec:    getEnv("CACHE_CODEC", "json"),
+        CacheTTL:      getEnvAsDuration("CACHE_TTL", 5*time.Minute),
+        VersionTTL:    getEnvAsDuration("CACHE_VERSION_TTL", 24*time.Hour),
+
+        MetricsAddr:     getEnv("METRICS_ADDR", ":10672"),
+        TracingEndpoint: getEnv("TRACING_ENDPOINT", "jaeger:4317"),
+        ShutdownTimeout: getEnvAsDuration("SHUTDOWN_TIMEOUT", 15*time.Second),
+    }
+}
+
+func getEnv(key, fallback string) string {
+    if val := os.Getenv(key); val != "" {
+        return val
+    }
+    return fallback
+}
+
+func getEnvAsInt(key string, defaultVal int) int {
+    valStr := os.Getenv(key)
+    if valStr == "" {
+        return defaultVal
+    }
+    val, err := strconv.Atoi(valStr)
+    if err != nil || val <= 0 {
+        log.Fatalf("Invalid %s value: %q", key, valStr)
+    }
+    return val
+}
+
+func getEnvAsDuration(key string, defaultVal time.Duration) time.Duration {
+    valStr := os.Getenv(key)
+    if valStr == "" {
+        return defaultVal
+    }
+    val, err := time.ParseDuration(valStr)
+    if err != nil {
+        log.Fatalf("Invalid %s value: %v", key, err)
     }
+    return val
 }
@@ -0,0 +1,406 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Riter/E-Shop/facade-consumer/internal/metrics"
+	"github.com/Riter/E-Shop/facade-consumer/internal/tracing"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Headers added to messages moved to the dead-letter topic.
+const (
+	HeaderError             = "x-error"
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderAttempts          = "x-attempts"
+	HeaderFailedAt          = "x-failed-at"
+)
+
+// Handler processes a single message. Errors wrapped with Permanent are not
+// retried.
+type Handler func(ctx context.Context, m kafka.Message) error
+
+// BatchHandler processes msgs in order and returns one error per message. It
+// is called concurrently, but never with two batches sharing a key.
+type BatchHandler func(ctx context.Context, msgs []kafka.Message) []error
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one retrying cannot fix, e.g. a malformed payload.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// delay returns the backoff before attempt n+1, doubling from Backoff.
+func (p RetryPolicy) delay(n int) time.Duration {
+	d := p.Backoff << (n - 1)
+	if d <= 0 || d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+type Options struct {
+	Retry RetryPolicy
+
+	// Operation names the operation a message carries, for metric labels.
+	// It may be nil.
+	Operation func(kafka.Message) string
+
+	// BatchHandle, if set, is tried first for each worker's share of a batch;
+	// messages it fails are retried one by one with the Handler.
+	BatchHandle BatchHandler
+
+	// Key returns the ordering key of a message: messages with equal keys are
+	// processed by one worker in offset order. If nil, the Kafka message key
+	// is used, falling back to the partition.
+	Key func(kafka.Message) string
+
+	// BatchSize is the maximum number of messages fetched before processing;
+	// BatchTimeout bounds how long a started batch waits to fill up.
+	BatchSize    int
+	BatchTimeout time.Duration
+	Workers      int
+
+	// DrainTimeout is how long the batch in flight at shutdown may take to
+	// finish and be committed before it is abandoned to redelivery.
+	DrainTimeout time.Duration
+}
+
+// Consumer commits an offset only after its message was handled or parked in
+// the dead-letter topic, so a failed invalidation is never silently lost.
+//
+// Messages are fetched in batches and spread over workers by key. A batch is
+// committed as a whole once every worker is done with its share, so at most
+// one batch is redelivered after a crash.
+type Consumer struct {
+	reader Reader
+	dlq    Writer
+	handle Handler
+	opts   Options
+	tracer trace.Tracer
+}
+
+func New(reader Reader, dlq Writer, handle Handler, opts Options) *Consumer {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+
+	return &Consumer{
+		reader: reader,
+		dlq:    dlq,
+		handle: handle,
+		opts:   opts,
+		tracer: otel.Tracer("facade-consumer/consumer"),
+	}
+}
+
+// Run processes messages until ctx is done. The batch being processed at
+// that moment gets DrainTimeout to finish, so a clean shutdown leaves no
+// handled-but-uncommitted offsets behind.
+func (c *Consumer) Run(ctx context.Context) error {
+	procCtx, cancelProc := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelProc()
+	stopDrain := context.AfterFunc(ctx, func() {
+		time.AfterFunc(c.opts.DrainTimeout, cancelProc)
+	})
+	defer stopDrain()
+
+	for ctx.Err() == nil {
+		batch := c.fetch(ctx)
+		if len(batch) == 0 {
+			continue
+		}
+
+		if err := c.process(procCtx, batch); err != nil {
+			if procCtx.Err() != nil {
+				log.Printf("Shutdown interrupted a batch of %d messages, it will be redelivered", len(batch))
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// fetch waits for one message and then collects more until the batch is
+// full or BatchTimeout passes.
+func (c *Consumer) fetch(ctx context.Context) []kafka.Message {
+	m, err := c.reader.FetchMessage(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Error fetching Kafka message: %v", err)
+			sleep(ctx, time.Second)
+		}
+		return nil
+	}
+	batch := []kafka.Message{m}
+
+	if c.opts.BatchSize > 1 {
+		fillCtx, cancel := context.WithTimeout(ctx, c.opts.BatchTimeout)
+		defer cancel()
+
+		for len(batch) < c.opts.BatchSize {
+			m, err := c.reader.FetchMessage(fillCtx)
+			if err != nil {
+				break
+			}
+			batch = append(batch, m)
+		}
+	}
+
+	for _, m := range batch {
+		metrics.ConsumerLag.WithLabelValues(m.Topic, strconv.Itoa(m.Partition)).Set(float64(max(m.HighWaterMark-m.Offset-1, 0)))
+	}
+	metrics.BatchSize.Observe(float64(len(batch)))
+	return batch
+}
+
+func (c *Consumer) process(ctx context.Context, batch []kafka.Message) error {
+	groups := c.schedule(batch)
+
+	errs := make([]error, len(groups))
+	var wg sync.WaitGroup
+	for i, group := range groups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.processGroup(ctx, group)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	// Later commits imply earlier offsets, so this must succeed before moving
+	// on; otherwise a failure could be skipped over.
+	for attempt := 1; ; attempt++ {
+		err := c.reader.CommitMessages(ctx, batch...)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Failed to commit a batch of %d messages: %v", len(batch), err)
+		if !sleep(ctx, c.opts.Retry.delay(attempt)) {
+			return ctx.Err()
+		}
+	}
+}
+
+// schedule splits batch between workers by key, keeping the offset order
+// within each worker's share.
+func (c *Consumer) schedule(batch []kafka.Message) [][]kafka.Message {
+	shares := make([][]kafka.Message, c.opts.Workers)
+	for _, m := range batch {
+		h := fnv.New32a()
+		h.Write([]byte(c.key(m)))
+		w := int(h.Sum32() % uint32(c.opts.Workers))
+		shares[w] = append(shares[w], m)
+	}
+
+	groups := shares[:0]
+	for _, share := range shares {
+		if len(share) > 0 {
+			groups = append(groups, share)
+		}
+	}
+	return groups
+}
+
+func (c *Consumer) key(m kafka.Message) string {
+	if c.opts.Key != nil {
+		if key := c.opts.Key(m); key != "" {
+			return key
+		}
+	}
+	if len(m.Key) > 0 {
+		return string(m.Key)
+	}
+	return m.Topic + "/" + strconv.Itoa(m.Partition)
+}
+
+// processGroup handles one worker's share. A message that fails in the batch
+// call is retried after later messages of the same key were applied; that is
+// safe because invalidations are idempotent and write-through is versioned.
+func (c *Consumer) processGroup(ctx context.Context, msgs []kafka.Message) error {
+	ctxs := make([]context.Context, len(msgs))
+	spans := make([]trace.Span, len(msgs))
+	operations := make([]string, len(msgs))
+	links := make([]trace.Link, len(msgs))
+	for i := range msgs {
+		ctxs[i], spans[i] = c.startSpan(ctx, msgs[i])
+		defer spans[i].End()
+
+		operations[i] = "unknown"
+		if c.opts.Operation != nil {
+			operations[i] = c.opts.Operation(msgs[i])
+		}
+		spans[i].SetAttributes(attribute.String("operation", operations[i]))
+		links[i] = trace.Link{SpanContext: spans[i].SpanContext()}
+	}
+
+	var batchErrs []error
+	if c.opts.BatchHandle != nil {
+		batchCtx, span := c.tracer.Start(ctx, "handle batch", trace.WithLinks(links...))
+		batchErrs = c.opts.BatchHandle(batchCtx, msgs)
+		span.End()
+	}
+
+	for i, m := range msgs {
+		var attempts int
+		var err error
+		retry := true
+		if batchErrs != nil {
+			attempts, err = 1, batchErrs[i]
+			retry = err != nil && !IsPermanent(err) && attempts < c.opts.Retry.MaxAttempts
+			if retry {
+				log.Printf("Batch attempt for offset %d failed, retrying: %v", m.Offset, err)
+				metrics.MessageRetries.WithLabelValues(operations[i]).Inc()
+				if !sleep(ctx, c.opts.Retry.delay(attempts)) {
+					return ctx.Err()
+				}
+			}
+		}
+		if retry {
+			var n int
+			n, err = c.handleWithRetry(ctxs[i], m, operations[i], c.opts.Retry.MaxAttempts-attempts)
+			attempts += n
+		}
+
+		if err == nil {
+			metrics.MessagesProcessed.WithLabelValues(operations[i]).Inc()
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		spans[i].RecordError(err)
+		spans[i].SetStatus(codes.Error, "moved to dead-letter topic")
+		metrics.MessagesFailed.WithLabelValues(operations[i]).Inc()
+
+		log.Printf("Moving message at offset %d to dead-letter topic after %d attempts: %v", m.Offset, attempts, err)
+		if err := c.deadLetter(ctxs[i], m, attempts, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Consumer) startSpan(ctx context.Context, m kafka.Message) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, tracing.HeaderCarrier{Headers: &m.Headers})
+	return c.tracer.Start(ctx, m.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("kafka"),
+			semconv.MessagingSourceName(m.Topic),
+			semconv.MessagingKafkaSourcePartition(m.Partition),
+			semconv.MessagingKafkaMessageOffset(int(m.Offset)),
+		),
+	)
+}
+
+// handleWithRetry makes up to maxAttempts attempts and returns how many it
+// made.
+func (c *Consumer) handleWithRetry(ctx context.Context, m kafka.Message, operation string, maxAttempts int) (int, error) {
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = c.handle(ctx, m); err == nil {
+			return attempt, nil
+		}
+		if IsPermanent(err) || attempt == maxAttempts {
+			return attempt, err
+		}
+
+		log.Printf("Attempt %d for offset %d failed, retrying: %v", attempt, m.Offset, err)
+		metrics.MessageRetries.WithLabelValues(operation).Inc()
+		if !sleep(ctx, c.opts.Retry.delay(attempt)) {
+			return attempt, ctx.Err()
+		}
+	}
+	return 0, err
+}
+
+// deadLetter keeps retrying until the message is stored: committing past a
+// message that is neither handled nor parked would lose it.
+func (c *Consumer) deadLetter(ctx context.Context, m kafka.Message, attempts int, cause error) error {
+	dead := kafka.Message{
+		Key:   m.Key,
+		Value: m.Value,
+		Headers: append(m.Headers[:len(m.Headers):len(m.Headers)],
+			kafka.Header{Key: HeaderError, Value: []byte(cause.Error())},
+			kafka.Header{Key: HeaderOriginalTopic, Value: []byte(m.Topic)},
+			kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(m.Partition))},
+			kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+			kafka.Header{Key: HeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+			kafka.Header{Key: HeaderFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+		),
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.dlq.WriteMessages(ctx, dead)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Failed to write offset %d to dead-letter topic: %v", m.Offset, err)
+		if !sleep(ctx, c.opts.Retry.delay(attempt)) {
+			return fmt.Errorf("dead-letter offset %d: %w", m.Offset, ctx.Err())
+		}
+	}
+}
+
+// sleep waits for d and reports false if ctx was cancelled first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,192 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type fakeReader struct {
+	msgs      []kafka.Message
+	committed []int64
+	cancel    context.CancelFunc
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.msgs) == 0 {
+		r.cancel()
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	m := r.msgs[0]
+	r.msgs = r.msgs[1:]
+	return m, nil
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	for _, m := range msgs {
+		r.committed = append(r.committed, m.Offset)
+	}
+	return nil
+}
+
+type fakeWriter struct {
+	msgs []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+var policy = RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+func run(t *testing.T, handle Handler, msgs ...kafka.Message) (*fakeReader, *fakeWriter) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reader := &fakeReader{msgs: msgs, cancel: cancel}
+	dlq := &fakeWriter{}
+	if err := New(reader, dlq, handle, Options{Retry: policy}).Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return reader, dlq
+}
+
+func TestRetriesTransientErrors(t *testing.T) {
+	calls := 0
+	reader, dlq := run(t, func(context.Context, kafka.Message) error {
+		calls++
+		if calls < 3 {
+			return errors.New("redis is down")
+		}
+		return nil
+	}, kafka.Message{Offset: 7})
+
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+	if len(dlq.msgs) != 0 {
+		t.Errorf("expected no dead letters, got %d", len(dlq.msgs))
+	}
+	if len(reader.committed) != 1 || reader.committed[0] != 7 {
+		t.Errorf("expected offset 7 to be committed, got %v", reader.committed)
+	}
+}
+
+func TestPermanentErrorGoesToDLQWithoutRetry(t *testing.T) {
+	calls := 0
+	reader, dlq := run(t, func(context.Context, kafka.Message) error {
+		calls++
+		return Permanent(errors.New("bad payload"))
+	}, kafka.Message{Topic: "item-events", Partition: 1, Offset: 3, Value: []byte("{")})
+
+	if calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", calls)
+	}
+	if len(dlq.msgs) != 1 {
+		t.Fatalf("expected 1 dead letter, got %d", len(dlq.msgs))
+	}
+	headers := map[string]string{}
+	for _, h := range dlq.msgs[0].Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	if headers[HeaderError] != "bad payload" || headers[HeaderOriginalTopic] != "item-events" ||
+		headers[HeaderOriginalPartition] != "1" || headers[HeaderOriginalOffset] != "3" || headers[HeaderAttempts] != "1" {
+		t.Errorf("unexpected dead-letter headers %v", headers)
+	}
+	if len(reader.committed) != 1 {
+		t.Errorf("expected the dead-lettered offset to be committed, got %v", reader.committed)
+	}
+}
+
+func TestExhaustedRetriesGoToDLQ(t *testing.T) {
+	calls := 0
+	_, dlq := run(t, func(context.Context, kafka.Message) error {
+		calls++
+		return errors.New("redis is down")
+	}, kafka.Message{Offset: 1})
+
+	if calls != policy.MaxAttempts {
+		t.Errorf("expected %d attempts, got %d", policy.MaxAttempts, calls)
+	}
+	if len(dlq.msgs) != 1 {
+		t.Errorf("expected 1 dead letter, got %d", len(dlq.msgs))
+	}
+}
+
+func TestShutdownCommitsMessageInFlight(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reader := &fakeReader{msgs: []kafka.Message{{Offset: 5}, {Offset: 6}}, cancel: cancel}
+	handle := func(ctx context.Context, _ kafka.Message) error {
+		// SIGTERM arrives while the message is being handled.
+		cancel()
+		return ctx.Err()
+	}
+
+	opts := Options{Retry: policy, DrainTimeout: time.Second}
+	if err := New(reader, &fakeWriter{}, handle, opts).Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(reader.committed) != 1 || reader.committed[0] != 5 {
+		t.Errorf("expected only offset 5 to be committed, got %v", reader.committed)
+	}
+}
+
+func TestBatchKeepsPerKeyOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var msgs []kafka.Message
+	for i := 0; i < 20; i++ {
+		msgs = append(msgs, kafka.Message{Offset: int64(i), Key: []byte{byte('a' + i%3)}})
+	}
+	reader := &fakeReader{msgs: msgs, cancel: cancel}
+
+	var mu sync.Mutex
+	seen := map[string][]int64{}
+	batchHandle := func(_ context.Context, msgs []kafka.Message) []error {
+		errs := make([]error, len(msgs))
+		for i, m := range msgs {
+			mu.Lock()
+			seen[string(m.Key)] = append(seen[string(m.Key)], m.Offset)
+			mu.Unlock()
+			if m.Offset == 4 {
+				errs[i] = errors.New("redis is down")
+			}
+		}
+		return errs
+	}
+	retried := 0
+	handle := func(context.Context, kafka.Message) error {
+		retried++
+		return nil
+	}
+
+	opts := Options{Retry: policy, BatchHandle: batchHandle, BatchSize: 50, BatchTimeout: time.Second, Workers: 4, DrainTimeout: time.Second}
+	if err := New(reader, &fakeWriter{}, handle, opts).Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for key, offsets := range seen {
+		for i := 1; i < len(offsets); i++ {
+			if offsets[i] < offsets[i-1] {
+				t.Errorf("key %s handled out of order: %v", key, offsets)
+			}
+		}
+	}
+	if retried != 1 {
+		t.Errorf("expected the failed message to be retried once, got %d", retried)
+	}
+	if len(reader.committed) != len(msgs) {
+		t.Errorf("expected the whole batch to be committed, got %v", reader.committed)
+	}
+}
@@ -0,0 +1,296 @@
+package invalidation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/Riter/E-Shop/common/codec"
+	"github.com/Riter/E-Shop/common/events"
+	"github.com/Riter/E-Shop/facade-consumer/internal/cache"
+	"github.com/Riter/E-Shop/facade-consumer/internal/consumer"
+	"github.com/go-redis/redis/v8"
+	"github.com/segmentio/kafka-go"
+)
+
+// Cache write modes.
+const (
+	// ModeInvalidate deletes the key and lets facade reload it from Postgres.
+	ModeInvalidate = "invalidate"
+	// ModeWriteThrough stores the product from the event right away.
+	ModeWriteThrough = "write-through"
+)
+
+// Version sources for write-through mode.
+const (
+	// VersionUpdatedAt orders events by item.updated_at, falling back to the
+	// event's occurred_at and then to the Kafka message timestamp. It is the
+	// default: a replayed event keeps its time, so it can't overwrite newer
+	// data.
+	VersionUpdatedAt = "updated_at"
+	// VersionOffset orders events by Kafka offset. It relies on all events of
+	// a product going to one partition, and a DLQ replay gets a new, higher
+	// offset than the events it missed: don't replay dead letters in this
+	// mode.
+	VersionOffset = "offset"
+)
+
+func contentType(m kafka.Message) string {
+	for _, h := range m.Headers {
+		if h.Key == events.HeaderContentType {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// decode reads and validates the product event in m. Invalid events are
+// permanent errors.
+func decode(m kafka.Message) (events.ProductEvent, error) {
+	e, err := events.Unmarshal(m.Value, contentType(m))
+	if err != nil {
+		return events.ProductEvent{}, consumer.Permanent(err)
+	}
+	return e, nil
+}
+
+// decodeRef reads only what invalidation needs, so an event whose item is
+// malformed still drops the key. Events without a valid operation or item ID
+// are permanent errors.
+func decodeRef(m kafka.Message) (events.Ref, error) {
+	ref, err := events.UnmarshalRef(m.Value, contentType(m))
+	if err != nil {
+		return events.Ref{}, consumer.Permanent(err)
+	}
+	return ref, nil
+}
+
+// Operation returns the name of m's operation type, or "unknown".
+func Operation(m kafka.Message) string {
+	ref, err := decodeRef(m)
+	if err != nil {
+		return "unknown"
+	}
+	return ref.Operation.String()
+}
+
+// Key returns the product ID of m, so that events of one product are never
+// handled concurrently. Malformed messages yield "".
+func Key(m kafka.Message) string {
+	ref, err := decodeRef(m)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatInt(ref.ItemID, 10)
+}
+
+type Options struct {
+	InvalidationChannel string
+	Mode                string
+	VersionSource       string
+	// PriceCurrency is the currency item prices are in, the same as facade's
+	// PRICE_CURRENCY.
+	PriceCurrency string
+	// Codec encodes written entries, the same as facade's CACHE_CODEC.
+	Codec codec.Codec
+}
+
+// Handler keeps facade's cache in sync with product events.
+type Handler struct {
+	rdb   *redis.Client
+	store *cache.Store
+	opts  Options
+}
+
+func NewHandler(rdb *redis.Client, store *cache.Store, opts Options) *Handler {
+	return &Handler{rdb: rdb, store: store, opts: opts}
+}
+
+func (h *Handler) Handle(ctx context.Context, m kafka.Message) error {
+	log.Printf("Received message at offset %d: %s\n", m.Offset, string(m.Value))
+
+	ref, err := decodeRef(m)
+	if err != nil {
+		return err
+	}
+
+	// CREATE drops the not-found tombstone facade may have cached for this ID.
+	key := strconv.FormatInt(ref.ItemID, 10)
+	if h.opts.Mode == ModeWriteThrough {
+		err = h.writeThrough(ctx, m, key)
+	} else {
+		err = h.invalidate(ctx, key)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Replicas that miss this only serve the stale value until their local
+	// TTL expires, so a failed publish is retried like a failed delete.
+	if err := h.rdb.Publish(ctx, h.opts.InvalidationChannel, key).Err(); err != nil {
+		return fmt.Errorf("publish invalidation for key %s: %w", key, err)
+	}
+	return nil
+}
+
+// HandleBatch handles msgs in order. In invalidate mode all keys are dropped
+// with one pipelined UNLINK and their invalidations published in the same
+// round trip; write-through entries are written one by one, since each needs
+// a versioned compare-and-set.
+func (h *Handler) HandleBatch(ctx context.Context, msgs []kafka.Message) []error {
+	errs := make([]error, len(msgs))
+	if h.opts.Mode == ModeWriteThrough {
+		for i, m := range msgs {
+			errs[i] = h.Handle(ctx, m)
+		}
+		return errs
+	}
+
+	var keys []string
+	var valid []int
+	seen := make(map[string]bool, len(msgs))
+	for i, m := range msgs {
+		ref, err := decodeRef(m)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		valid = append(valid, i)
+		key := strconv.FormatInt(ref.ItemID, 10)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return errs
+	}
+
+	pipe := h.rdb.Pipeline()
+	pipe.Unlink(ctx, keys...)
+	for _, key := range keys {
+		pipe.Publish(ctx, h.opts.InvalidationChannel, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		err = fmt.Errorf("invalidate %d keys: %w", len(keys), err)
+		for _, i := range valid {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	log.Printf("Invalidated %d keys from %d messages", len(keys), len(msgs))
+	return errs
+}
+
+func (h *Handler) invalidate(ctx context.Context, key string) error {
+	deleted, err := h.rdb.Del(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("delete key %s: %w", key, err)
+	}
+
+	if deleted > 0 {
+		log.Printf("Deleted key %s from Redis", key)
+	} else {
+		log.Printf("Key %s not found in Redis", key)
+	}
+	return nil
+}
+
+// writeThrough stores the item of m. An event that fails full validation
+// can't be cached, so its key is deleted for facade to reload it.
+func (h *Handler) writeThrough(ctx context.Context, m kafka.Message, key string) error {
+	e, err := decode(m)
+	if err != nil {
+		log.Printf("Invalid event for key %s, deleting it instead: %v", key, err)
+		e = events.ProductEvent{Operation: events.OpDelete}
+	}
+	version := h.version(m, e)
+
+	var value string
+	if e.Operation != events.OpDelete {
+		if value, err = h.entry(ctx, key, e); err != nil {
+			return err
+		}
+	}
+
+	var applied bool
+	if value == "" {
+		applied, err = h.store.Delete(ctx, key, version)
+	} else {
+		applied, err = h.store.Put(ctx, key, value, version)
+	}
+	if err != nil {
+		return fmt.Errorf("write key %s: %w", key, err)
+	}
+
+	switch {
+	case !applied:
+		log.Printf("Skipped key %s: version %d is not newer than the cached one", key, version)
+	case value == "":
+		log.Printf("Deleted key %s from Redis", key)
+	default:
+		log.Printf("Wrote key %s to Redis", key)
+	}
+	return nil
+}
+
+// entry builds the cache value for the item of e. A created product has no
+// attributes and variants yet. Events don't carry them, so for a change they
+// are taken from the current entry; if there is none to update, "" is
+// returned and the key is deleted instead, for facade to reload it.
+func (h *Handler) entry(ctx context.Context, key string, e events.ProductEvent) (string, error) {
+	var product cache.Product
+	if e.Operation == events.OpCreate {
+		p, err := cache.New(*e.Item, h.opts.PriceCurrency)
+		if err != nil {
+			return "", consumer.Permanent(fmt.Errorf("convert product %s: %w", key, err))
+		}
+		product = p
+	} else {
+		p, ok, err := h.update(ctx, key, *e.Item)
+		if err != nil || !ok {
+			return "", err
+		}
+		product = p
+	}
+
+	value, err := cache.Encode(h.opts.Codec, product)
+	if err != nil {
+		return "", consumer.Permanent(fmt.Errorf("encode product %s: %w", key, err))
+	}
+	return value, nil
+}
+
+// update applies item to the cached product. ok is false if the key is cold,
+// holds a not-found tombstone, or can't be repriced.
+func (h *Handler) update(ctx context.Context, key string, item events.Item) (product cache.Product, ok bool, err error) {
+	raw, err := h.store.Get(ctx, key)
+	if err != nil {
+		return cache.Product{}, false, fmt.Errorf("get key %s: %w", key, err)
+	}
+	if raw == "" || raw == cache.NotFound {
+		return cache.Product{}, false, nil
+	}
+
+	var cached cache.Product
+	if err := cache.Decode(raw, &cached); err != nil {
+		log.Printf("Cached value of key %s is not a product: %v", key, err)
+		return cache.Product{}, false, nil
+	}
+
+	product, ok, err = cache.Update(cached, item, h.opts.PriceCurrency)
+	if err != nil {
+		return cache.Product{}, false, consumer.Permanent(fmt.Errorf("update product %s: %w", key, err))
+	}
+	return product, ok, nil
+}
+
+func (h *Handler) version(m kafka.Message, e events.ProductEvent) int64 {
+	if h.opts.VersionSource == VersionOffset {
+		return m.Offset
+	}
+	return e.Version(m.Time).UnixMicro()
+}
@@ -0,0 +1,42 @@
+package invalidation
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestKeyIgnoresMalformedItem(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantKey string
+		wantOp  string
+	}{
+		{
+			name:    "naive created_at from the Python producer",
+			value:   `{"schema_version": 1, "occurred_at": "2025-05-01T12:00:03.512034+00:00", "operation_type": 2, "item_id": 7, "item": {"id": 7, "price": 129.0, "created_at": "2025-05-01T12:00:00.250000", "images": []}}`,
+			wantKey: "7",
+			wantOp:  "change",
+		},
+		{
+			name:    "mismatched item id",
+			value:   `{"operation_type": 2, "item_id": 7, "item": {"id": 8, "price": 129.0}}`,
+			wantKey: "7",
+			wantOp:  "change",
+		},
+		{name: "missing item id", value: `{"operation_type": 2}`, wantKey: "", wantOp: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := kafka.Message{Value: []byte(tt.value)}
+			if got := Key(m); got != tt.wantKey {
+				t.Errorf("expected key %q, got %q", tt.wantKey, got)
+			}
+			if got := Operation(m); got != tt.wantOp {
+				t.Errorf("expected operation %q, got %q", tt.wantOp, got)
+			}
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package kafkaclient
 
 import (
+    "time"
+
     "github.com/segmentio/kafka-go"
 )
 
+// NewReader returns a group reader; offsets are committed explicitly with
+// CommitMessages after a message was processed.
 func NewReader(brokers []string, topic, groupID string) *kafka.Reader {
     return kafka.NewReader(kafka.ReaderConfig{
         Brokers:  brokers,
@@ -13,3 +17,13 @@ func NewReader(brokers []string, topic, groupID string) *kafka.Reader {
         MaxBytes: 10e6, 
     })
 }
+
+func NewWriter(brokers []string, topic string) *kafka.Writer {
+    return &kafka.Writer{
+        Addr:         kafka.TCP(brokers...),
+        Topic:        topic,
+        Balancer:     &kafka.Hash{},
+        RequiredAcks: kafka.RequireAll,
+        BatchTimeout: 10 * time.Millisecond,
+    }
+}
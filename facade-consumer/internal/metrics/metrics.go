@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	prometheus.MustRegister(ConsumerLag, BatchSize, MessagesProcessed, MessagesFailed, MessageRetries, RedisDuration, RedisErrors)
+}
+
+var (
+	ConsumerLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "facade_consumer_lag",
+		Help: "messages behind the partition's high watermark as of the last fetched message",
+	}, []string{"topic", "partition"})
+
+	BatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "facade_consumer_batch_size",
+		Help:    "number of messages fetched per batch",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	})
+
+	MessagesProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "facade_consumer_messages_processed_total",
+		Help: "number of messages handled successfully by operation type",
+	}, []string{"operation"})
+
+	MessagesFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "facade_consumer_messages_failed_total",
+		Help: "number of messages moved to the dead-letter topic by operation type",
+	}, []string{"operation"})
+
+	MessageRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "facade_consumer_message_retries_total",
+		Help: "number of retried handling attempts by operation type",
+	}, []string{"operation"})
+
+	RedisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "facade_consumer_redis_duration_seconds",
+		Help:    "duration of Redis commands",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"command"})
+
+	RedisErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "facade_consumer_redis_errors_total",
+		Help: "number of failed Redis commands",
+	}, []string{"command"})
+)
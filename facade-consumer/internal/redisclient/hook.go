@@ -0,0 +1,49 @@
+package redisclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/Riter/E-Shop/facade-consumer/internal/metrics"
+	"github.com/go-redis/redis/v8"
+)
+
+type startKey struct{}
+
+// metricsHook records the duration and errors of every Redis command.
+type metricsHook struct{}
+
+func (metricsHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, startKey{}, time.Now()), nil
+}
+
+func (metricsHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	observe(ctx, cmd.Name(), cmd.Err())
+	return nil
+}
+
+func (metricsHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, startKey{}, time.Now()), nil
+}
+
+func (metricsHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil {
+			err = cmd.Err()
+			break
+		}
+	}
+	observe(ctx, "pipeline", err)
+	return nil
+}
+
+func observe(ctx context.Context, command string, err error) {
+	if start, ok := ctx.Value(startKey{}).(time.Time); ok {
+		metrics.RedisDuration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+	}
+	// A missing key is a regular answer, not a failure.
+	if err != nil && err != redis.Nil {
+		metrics.RedisErrors.WithLabelValues(command).Inc()
+	}
+}
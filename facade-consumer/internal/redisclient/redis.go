@@ -14,6 +14,8 @@ func NewClient(addr, password string, db int) *redis.Client {
         DB:       db,
     })
 
+    rdb.AddHook(metricsHook{})
+
     ctx := context.Background()
     if err := rdb.Ping(ctx).Err(); err != nil {
         log.Fatalf("Failed to connect to Redis: %v", err)
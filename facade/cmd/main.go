@@ -5,12 +5,22 @@ import (
 	"log"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/Riter/E-Shop/common/httperr"
+	grpcapp "github.com/Riter/E-Shop/internal/app/grpc"
+	"github.com/Riter/E-Shop/common/codec"
 	"github.com/Riter/E-Shop/internal/config"
+	"github.com/Riter/E-Shop/internal/currency"
 	"github.com/Riter/E-Shop/internal/handlers"
 	psq "github.com/Riter/E-Shop/internal/storage/postgres"
 	"github.com/Riter/E-Shop/internal/storage/redis"
+	"github.com/Riter/E-Shop/internal/storage/tiered"
+	"github.com/Riter/E-Shop/internal/views"
+	"github.com/Riter/E-Shop/internal/warmup"
 	"github.com/go-chi/chi/v5"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
     "go.opentelemetry.io/otel"
@@ -83,29 +93,124 @@ func main() {
     if err != nil {
         log.Fatalf("Ошибка подключения к БД: %v", err)
     }
+	defer dbClient.Close()
 
 	r := chi.NewRouter()
 	r.Use(otelhttp.NewMiddleware("facade-service"))
+	r.Use(httperr.RequestIDMiddleware)
 
 	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("pong"))
 	})
-
-
+	r.Get("/healthz", handlers.Healthz)
+	r.Get("/readyz", handlers.Readyz(map[string]handlers.Check{
+		"redis": func(ctx context.Context) error {
+			_, err := rdb.Ping(ctx)
+			return err
+		},
+		"postgres": dbClient.Ping,
+	}))
+
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", promhttp.Handler())
+	metricsSrv := &http.Server{Addr: ":10671", Handler: metricsMux}
 	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-			if err := http.ListenAndServe(":10671", nil); err != nil {
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 				slog.Error("failed to start metrics server", slog.Any("err", err))
 			}
   	}()
 
 
-	r.Get("/products", handlers.GetProducts(ctx, dbClient, rdb))
+	bgCtx, stopBg := context.WithCancel(context.Background())
+	defer stopBg()
 
-	
-	log.Println("Listening on :8089")
-	if err := http.ListenAndServe(":8089", r); err != nil {
-		log.Fatalf("server failed: %v", err)
+	rates := currency.NewRates(cfg.PriceCurrency)
+	currencyCfg := config.LoadCurrencyConfig()
+	refresher := currency.NewRefresher(rates, dbClient, currencyCfg.ProviderURL, currencyCfg.RefreshInterval)
+	if err := refresher.Refresh(ctx); err != nil {
+		log.Printf("failed to load currency rates, only %s prices are available: %v", rates.Base(), err)
+	}
+	go refresher.Run(bgCtx)
+
+	cacheCfg := config.LoadCacheConfig()
+	cache := tiered.New(rdb, cacheCfg.LocalSize, cacheCfg.LocalTTL)
+
+	go rdb.Subscribe(bgCtx, cacheCfg.InvalidationChannel, handlers.ProductInvalidator(bgCtx, cache, rdb, rates))
+	go func() {
+		if err := psq.ListenProductChanges(bgCtx, cfg, handlers.ProductChangeInvalidator(bgCtx, cache, rdb, rates)); err != nil {
+			log.Printf("product change listener stopped: %v", err)
+		}
+	}()
+
+	valueCodec, err := codec.ByName(cacheCfg.Codec)
+	if err != nil {
+		log.Fatalf("invalid cache codec: %v", err)
+	}
+
+	pipeline := handlers.Pipeline{Source: dbClient, Cacher: cache, Codec: valueCodec, Converter: rates}
+
+	viewsCfg := config.LoadViewsConfig()
+	var publisher views.Publisher
+	if len(viewsCfg.KafkaBrokers) > 0 {
+		kafkaPublisher := views.NewKafkaPublisher(viewsCfg.KafkaBrokers, viewsCfg.Topic, viewsCfg.BatchSize)
+		defer kafkaPublisher.Close()
+		publisher = kafkaPublisher
+	} else {
+		log.Println("KAFKA_BROKERS is not set, product_viewed events are not published")
+	}
+	tracker := views.NewTracker(viewsCfg, publisher, rdb)
+	go tracker.Run()
+
+	r.Get("/products", handlers.GetProducts(ctx, pipeline, tracker))
+	r.Get("/me/recently-viewed", handlers.RecentlyViewed(rdb, viewsCfg.RecentLimit, pipeline))
+
+	warmupCfg := config.LoadWarmupConfig()
+	warmer := warmup.New(warmupCfg, dbClient, cache, rdb, valueCodec)
+	defer warmer.Close()
+	if warmupCfg.Enabled {
+		go warmer.Watch()
+	}
+	r.Post("/admin/cache/warmup", handlers.TriggerWarmup(warmer))
+
+	grpcCfg := config.LoadGRPCConfig()
+	grpcApp := grpcapp.New(slog.Default(), pipeline, grpcCfg.Port)
+	go grpcApp.MustRun()
+
+	srv := &http.Server{
+		Addr:    ":8089",
+		Handler: r,
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		log.Println("Listening on :8089")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("server failed: %v", err)
+		}
+	}()
+
+	<-stop
+	log.Println("Shutting down, draining in-flight requests")
+	handlers.MarkShuttingDown()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancelShutdown()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("error during server shutdown: %v", err)
+	}
+	grpcApp.Stop()
+	if err := tracker.Close(shutdownCtx); err != nil {
+		log.Printf("pending product views were not flushed: %v", err)
+	}
+	if err := handlers.WaitPendingWrites(shutdownCtx); err != nil {
+		log.Printf("pending cache writes were not flushed: %v", err)
+	}
+	if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("error during metrics server shutdown: %v", err)
 	}
 	log.Println("Server stopped")
 
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"log"
+	"time"
+)
+
+type CacheConfig struct {
+	LocalSize           int
+	LocalTTL            time.Duration
+	InvalidationChannel string
+
+	// Codec is the format new cache values are written in: json, msgpack or zstd.
+	Codec string
+}
+
+func LoadCacheConfig() *CacheConfig {
+	return &CacheConfig{
+		LocalSize:           getEnvAsInt("LOCAL_CACHE_SIZE", 10000),
+		LocalTTL:            getEnvAsDuration("LOCAL_CACHE_TTL", 10*time.Second),
+		InvalidationChannel: getEnv("CACHE_INVALIDATION_CHANNEL", "product-invalidations"),
+		Codec:               getEnv("CACHE_CODEC", "json"),
+	}
+}
+
+func getEnvAsDuration(key string, defaultVal time.Duration) time.Duration {
+	if valStr := getEnv(key, ""); valStr != "" {
+		val, err := time.ParseDuration(valStr)
+		if err != nil {
+			log.Printf("Invalid %s value, using default %s: %v", key, defaultVal, err)
+			return defaultVal
+		}
+		return val
+	}
+	return defaultVal
+}
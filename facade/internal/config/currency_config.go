@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+type CurrencyConfig struct {
+	// ProviderURL is polled for fresh rates; when empty, rates are only read
+	// from the currency_rates table, which is then maintained externally.
+	ProviderURL     string
+	RefreshInterval time.Duration
+}
+
+func LoadCurrencyConfig() *CurrencyConfig {
+	return &CurrencyConfig{
+		ProviderURL:     getEnv("CURRENCY_RATES_URL", ""),
+		RefreshInterval: getEnvAsDuration("CURRENCY_REFRESH_INTERVAL", time.Hour),
+	}
+}
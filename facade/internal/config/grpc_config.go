@@ -0,0 +1,11 @@
+package config
+
+type GRPCConfig struct {
+	Port int
+}
+
+func LoadGRPCConfig() *GRPCConfig {
+	return &GRPCConfig{
+		Port: getEnvAsInt("GRPC_PORT", 8090),
+	}
+}
@@ -12,6 +12,9 @@ type PostgresConfig struct {
     Password string
     DBName   string
     SSLMode  string
+
+    // PriceCurrency is the ISO code prices in the products table are stored in.
+    PriceCurrency string
 }
 
 func LoadPostgresConfigFromEnv() PostgresConfig {
@@ -22,6 +25,8 @@ func LoadPostgresConfigFromEnv() PostgresConfig {
         Password: os.Getenv("POSTGRES_PASSWORD"),
         DBName:   os.Getenv("POSTGRES_NAME"),
         SSLMode:  getEnv("POSTGRES_SSLMODE", "disable"),
+
+        PriceCurrency: getEnv("PRICE_CURRENCY", "USD"),
     }
 }
 
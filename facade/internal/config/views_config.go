@@ -0,0 +1,37 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+type ViewsConfig struct {
+	// KafkaBrokers is empty when product_viewed events should not be
+	// published; views are still recorded in Redis then.
+	KafkaBrokers []string
+	Topic        string
+
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+
+	RecentLimit int
+	RecentTTL   time.Duration
+}
+
+func LoadViewsConfig() *ViewsConfig {
+	var brokers []string
+	if val := getEnv("KAFKA_BROKERS", ""); val != "" {
+		brokers = strings.Split(val, ",")
+	}
+
+	return &ViewsConfig{
+		KafkaBrokers:  brokers,
+		Topic:         getEnv("VIEWS_TOPIC", "product-views"),
+		QueueSize:     getEnvAsInt("VIEWS_QUEUE_SIZE", 10000),
+		BatchSize:     getEnvAsInt("VIEWS_BATCH_SIZE", 100),
+		FlushInterval: getEnvAsDuration("VIEWS_FLUSH_INTERVAL", time.Second),
+		RecentLimit:   getEnvAsInt("RECENTLY_VIEWED_LIMIT", 20),
+		RecentTTL:     getEnvAsDuration("RECENTLY_VIEWED_TTL", 30*24*time.Hour),
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type WarmupConfig struct {
+	Enabled bool
+
+	// Source is where the ranking comes from: "newest" takes the most
+	// recently created products from Postgres, "views" reads the view-count
+	// sorted set in Redis and falls back to "newest" while it is empty,
+	// "list" uses SKUs as given.
+	Source string
+	SKUs   []int64
+	TopN   int
+
+	BatchSize     int
+	BatchInterval time.Duration
+	TTL           time.Duration
+
+	// CheckInterval is how often facade looks for the warm-up marker key;
+	// a missing marker means Redis was flushed or restarted.
+	CheckInterval time.Duration
+
+	// LockTTL bounds how long one replica holds the warm-up lock. It has to
+	// outlast a warm-up, or another replica may start a second one.
+	LockTTL time.Duration
+}
+
+// LoadWarmupConfig exits if a setting is invalid: a zero batch size would
+// make the warm-up loop forever while holding the lock.
+func LoadWarmupConfig() *WarmupConfig {
+	cfg, err := loadWarmupConfig()
+	if err != nil {
+		log.Fatalf("Invalid warm-up config: %v", err)
+	}
+	return cfg
+}
+
+func loadWarmupConfig() (*WarmupConfig, error) {
+	cfg := &WarmupConfig{
+		Enabled:       getEnv("WARMUP_ENABLED", "true") == "true",
+		Source:        getEnv("WARMUP_SOURCE", "newest"),
+		SKUs:          getEnvAsInt64List("WARMUP_SKUS"),
+		TopN:          getEnvAsInt("WARMUP_TOP_N", 500),
+		BatchSize:     getEnvAsInt("WARMUP_BATCH_SIZE", 50),
+		BatchInterval: getEnvAsDuration("WARMUP_BATCH_INTERVAL", 200*time.Millisecond),
+		TTL:           getEnvAsDuration("WARMUP_TTL", 15*time.Minute),
+		CheckInterval: getEnvAsDuration("WARMUP_CHECK_INTERVAL", 30*time.Second),
+		LockTTL:       getEnvAsDuration("WARMUP_LOCK_TTL", 10*time.Minute),
+	}
+
+	// getEnvAsInt reads unparseable values as 0, so they fail here too
+	switch {
+	case cfg.TopN <= 0:
+		return nil, fmt.Errorf("WARMUP_TOP_N must be positive, got %d", cfg.TopN)
+	case cfg.BatchSize <= 0:
+		return nil, fmt.Errorf("WARMUP_BATCH_SIZE must be positive, got %d", cfg.BatchSize)
+	case cfg.BatchInterval <= 0:
+		return nil, fmt.Errorf("WARMUP_BATCH_INTERVAL must be positive, got %s", cfg.BatchInterval)
+	case cfg.CheckInterval <= 0:
+		return nil, fmt.Errorf("WARMUP_CHECK_INTERVAL must be positive, got %s", cfg.CheckInterval)
+	}
+	return cfg, nil
+}
+
+func getEnvAsInt64List(key string) []int64 {
+	valStr := getEnv(key, "")
+	if valStr == "" {
+		return nil
+	}
+
+	var vals []int64
+	for _, part := range strings.Split(valStr, ",") {
+		val, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			log.Printf("Invalid value %q in %s, skipping: %v", part, key, err)
+			continue
+		}
+		vals = append(vals, val)
+	}
+	return vals
+}
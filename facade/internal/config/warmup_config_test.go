@@ -0,0 +1,36 @@
+package config
+
+import "testing"
+
+func TestLoadWarmupConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantBatch int
+		wantErr   bool
+	}{
+		{name: "defaults", wantBatch: 50},
+		{name: "custom batch", env: map[string]string{"WARMUP_BATCH_SIZE": "10", "WARMUP_TOP_N": "100"}, wantBatch: 10},
+		{name: "zero batch size", env: map[string]string{"WARMUP_BATCH_SIZE": "0"}, wantErr: true},
+		{name: "unparseable batch size", env: map[string]string{"WARMUP_BATCH_SIZE": "fifty"}, wantErr: true},
+		{name: "negative top n", env: map[string]string{"WARMUP_TOP_N": "-1"}, wantErr: true},
+		{name: "zero batch interval", env: map[string]string{"WARMUP_BATCH_INTERVAL": "0s"}, wantErr: true},
+		{name: "negative check interval", env: map[string]string{"WARMUP_CHECK_INTERVAL": "-1s"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"WARMUP_BATCH_SIZE", "WARMUP_TOP_N", "WARMUP_BATCH_INTERVAL", "WARMUP_CHECK_INTERVAL"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			cfg, err := loadWarmupConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && cfg.BatchSize != tt.wantBatch {
+				t.Errorf("expected batch size %d, got %d", tt.wantBatch, cfg.BatchSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Riter/E-Shop/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+var ErrUnsupported = errors.New("unsupported currency")
+
+// Rates is an in-memory snapshot of the currency_rates table. A rate is the
+// amount of the currency that equals one unit of the base currency.
+type Rates struct {
+	base string
+
+	mu        sync.RWMutex
+	rates     map[string]decimal.Decimal
+	updatedAt time.Time
+}
+
+func NewRates(base string) *Rates {
+	return &Rates{base: base, rates: map[string]decimal.Decimal{}}
+}
+
+// Base is the currency product prices are stored in.
+func (r *Rates) Base() string {
+	return r.base
+}
+
+func (r *Rates) Replace(rates map[string]decimal.Decimal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rates = rates
+	r.updatedAt = time.Now()
+}
+
+func (r *Rates) Supported(code string) bool {
+	if code == r.base {
+		return true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.rates[code]
+	return ok
+}
+
+// Currencies lists the codes prices can be converted to, except the base one.
+func (r *Rates) Currencies() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	codes := make([]string, 0, len(r.rates))
+	for code := range r.rates {
+		if code != r.base {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Convert converts m into currency to and rounds it by that currency's rules.
+func (r *Rates) Convert(m models.Money, to string) (models.Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	if m.Currency != r.base {
+		return models.Money{}, fmt.Errorf("convert from %s: %w", m.Currency, ErrUnsupported)
+	}
+
+	r.mu.RLock()
+	rate, ok := r.rates[to]
+	r.mu.RUnlock()
+	if !ok {
+		return models.Money{}, fmt.Errorf("convert to %s: %w", to, ErrUnsupported)
+	}
+
+	return models.Money{Amount: Round(m.Amount.Mul(rate), to), Currency: to}, nil
+}
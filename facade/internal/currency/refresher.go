@@ -0,0 +1,104 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Store interface {
+	GetCurrencyRates(ctx context.Context) (map[string]decimal.Decimal, error)
+	UpsertCurrencyRates(ctx context.Context, rates map[string]decimal.Decimal) error
+}
+
+// Refresher keeps Rates in sync with the currency_rates table. When a
+// provider URL is configured it first pulls fresh rates from the provider
+// into the table, so every replica ends up reading the same values.
+type Refresher struct {
+	rates       *Rates
+	store       Store
+	providerURL string
+	interval    time.Duration
+	client      *http.Client
+}
+
+func NewRefresher(rates *Rates, store Store, providerURL string, interval time.Duration) *Refresher {
+	return &Refresher{
+		rates:       rates,
+		store:       store,
+		providerURL: providerURL,
+		interval:    interval,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Run refreshes rates every interval until ctx is done.
+func (f *Refresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.Refresh(ctx); err != nil {
+				log.Printf("failed to refresh currency rates: %v", err)
+			}
+		}
+	}
+}
+
+func (f *Refresher) Refresh(ctx context.Context) error {
+	if f.providerURL != "" {
+		fetched, err := f.fetch(ctx)
+		if err != nil {
+			// Stale rates from the table are still better than none.
+			log.Printf("failed to fetch currency rates from provider: %v", err)
+		} else if err := f.store.UpsertCurrencyRates(ctx, fetched); err != nil {
+			return fmt.Errorf("store rates: %w", err)
+		}
+	}
+
+	rates, err := f.store.GetCurrencyRates(ctx)
+	if err != nil {
+		return fmt.Errorf("load rates: %w", err)
+	}
+	f.rates.Replace(rates)
+	return nil
+}
+
+// fetch expects a response of the form {"base": "USD", "rates": {"EUR": 0.92}}.
+func (f *Refresher) fetch(ctx context.Context) (map[string]decimal.Decimal, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.providerURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Base  string                     `json:"base"`
+		Rates map[string]decimal.Decimal `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.Base != f.rates.Base() {
+		return nil, fmt.Errorf("provider base currency %s, expected %s", body.Base, f.rates.Base())
+	}
+	return body.Rates, nil
+}
@@ -0,0 +1,34 @@
+package currency
+
+import "github.com/shopspring/decimal"
+
+const defaultMinorUnits = 2
+
+// minorUnits holds ISO 4217 exponents that differ from the default of two.
+var minorUnits = map[string]int32{
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"OMR": 3,
+}
+
+// cashIncrements are currencies whose prices are shown rounded to a step
+// larger than their minor unit.
+var cashIncrements = map[string]decimal.Decimal{
+	"CHF": decimal.RequireFromString("0.05"),
+}
+
+// Round rounds amount half away from zero to what is displayable in code.
+func Round(amount decimal.Decimal, code string) decimal.Decimal {
+	if inc, ok := cashIncrements[code]; ok {
+		return amount.Div(inc).Round(0).Mul(inc)
+	}
+
+	places, ok := minorUnits[code]
+	if !ok {
+		places = defaultMinorUnits
+	}
+	return amount.Round(places)
+}
@@ -0,0 +1,27 @@
+package currency
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestRound(t *testing.T) {
+	cases := []struct {
+		amount, code, want string
+	}{
+		{"735.5908", "EUR", "735.59"},
+		{"735.595", "EUR", "735.6"},
+		{"118498.5", "JPY", "118499"},
+		{"245.9371", "KWD", "245.937"},
+		{"711.9721", "CHF", "711.95"},
+		{"711.9751", "CHF", "712"},
+	}
+
+	for _, c := range cases {
+		got := Round(decimal.RequireFromString(c.amount), c.code)
+		if !got.Equal(decimal.RequireFromString(c.want)) {
+			t.Errorf("Round(%s, %s) = %s, want %s", c.amount, c.code, got, c.want)
+		}
+	}
+}
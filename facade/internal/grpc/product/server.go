@@ -0,0 +1,158 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	productv1 "github.com/Riter/E-Shop/common/gen/go/product/v1"
+	"github.com/Riter/E-Shop/common/httperr"
+	"github.com/Riter/E-Shop/internal/currency"
+	"github.com/Riter/E-Shop/internal/handlers"
+	"github.com/Riter/E-Shop/internal/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Products is the lookup pipeline shared with the HTTP handlers.
+type Products interface {
+	GetProducts(ctx context.Context, skus []int64, currency string) (models.ProductResponseList, error)
+	Currency(raw string) (string, error)
+}
+
+type serverAPI struct {
+	productv1.UnimplementedProductServiceServer
+	products Products
+}
+
+func Register(gRPC *grpc.Server, products Products) {
+	productv1.RegisterProductServiceServer(gRPC, &serverAPI{products: products})
+}
+
+func (s *serverAPI) BatchGetProducts(
+	ctx context.Context, req *productv1.BatchGetProductsRequest,
+) (*productv1.BatchGetProductsResponse, error) {
+	skus, err := normalizeSKUs(req.GetSkus())
+	if err != nil {
+		return nil, err
+	}
+	if len(skus) > handlers.MaxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d skus can be requested at once", handlers.MaxBatchSize)
+	}
+	currency, err := s.products.Currency(req.GetCurrency())
+	if err != nil {
+		return nil, toStatus("BatchGetProducts", err)
+	}
+
+	result, err := s.products.GetProducts(ctx, skus, currency)
+	if err != nil {
+		return nil, toStatus("BatchGetProducts", err)
+	}
+
+	resp := &productv1.BatchGetProductsResponse{NotFound: result.NotFound}
+	for _, p := range result.ProductList {
+		resp.Products = append(resp.Products, toProto(p))
+	}
+	return resp, nil
+}
+
+func (s *serverAPI) ListProducts(
+	req *productv1.ListProductsRequest, stream grpc.ServerStreamingServer[productv1.Product],
+) error {
+	skus, err := normalizeSKUs(req.GetSkus())
+	if err != nil {
+		return err
+	}
+	currency, err := s.products.Currency(req.GetCurrency())
+	if err != nil {
+		return toStatus("ListProducts", err)
+	}
+
+	for start := 0; start < len(skus); start += handlers.MaxBatchSize {
+		end := min(start+handlers.MaxBatchSize, len(skus))
+
+		result, err := s.products.GetProducts(stream.Context(), skus[start:end], currency)
+		if err != nil {
+			return toStatus("ListProducts", err)
+		}
+
+		for _, p := range result.ProductList {
+			if err := stream.Send(toProto(p)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeSKUs validates skus and drops duplicates, keeping the request
+// order, so each product is looked up and returned once.
+func normalizeSKUs(skus []int64) ([]int64, error) {
+	if len(skus) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "skus are required")
+	}
+
+	unique := make([]int64, 0, len(skus))
+	seen := make(map[int64]struct{}, len(skus))
+	for _, sku := range skus {
+		if sku <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "sku must be a positive integer, got %d", sku)
+		}
+		if _, ok := seen[sku]; ok {
+			continue
+		}
+		seen[sku] = struct{}{}
+		unique = append(unique, sku)
+	}
+	return unique, nil
+}
+
+func toStatus(method string, err error) error {
+	if errors.Is(err, currency.ErrUnsupported) {
+		return status.Error(codes.InvalidArgument, "currency is not supported")
+	}
+	var apiErr *httperr.Error
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusBadRequest {
+		return status.Error(codes.InvalidArgument, apiErr.Message)
+	}
+
+	log.Printf("%s failed: %v", method, err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func toProto(p models.ProductResponse) *productv1.Product {
+	attributes := make([]*productv1.Attribute, 0, len(p.Attributes))
+	for _, a := range p.Attributes {
+		attributes = append(attributes, &productv1.Attribute{Key: a.Key, Value: a.Value, Type: a.Type})
+	}
+
+	variants := make([]*productv1.Variant, 0, len(p.Variants))
+	for _, v := range p.Variants {
+		variants = append(variants, &productv1.Variant{
+			Id:         int64(v.ID),
+			Sku:        v.SKU,
+			Price:      moneyToProto(v.Price),
+			Stock:      int32(v.Stock),
+			Attributes: v.Attributes,
+		})
+	}
+
+	return &productv1.Product{
+		Id:          int64(p.ID),
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       moneyToProto(p.Price),
+		Category:    p.Category,
+		CreatedAt:   timestamppb.New(p.CreatedAt),
+		Images:      p.Images,
+		Attributes:  attributes,
+		Variants:    variants,
+	}
+}
+
+func moneyToProto(m models.Money) *productv1.Money {
+	return &productv1.Money{Amount: m.Amount.String(), CurrencyCode: m.Currency}
+}
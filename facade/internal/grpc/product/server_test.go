@@ -0,0 +1,59 @@
+package product
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	productv1 "github.com/Riter/E-Shop/common/gen/go/product/v1"
+	"github.com/Riter/E-Shop/internal/handlers"
+	"github.com/Riter/E-Shop/internal/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeConverter struct{}
+
+func (fakeConverter) Base() string                                           { return "RUB" }
+func (fakeConverter) Currencies() []string                                   { return []string{"RUB", "USD"} }
+func (fakeConverter) Supported(c string) bool                                { return c == "RUB" || c == "USD" }
+func (fakeConverter) Convert(m models.Money, _ string) (models.Money, error) { return m, nil }
+
+type fakeProducts struct {
+	handlers.Pipeline
+	calls [][]int64
+}
+
+func (f *fakeProducts) GetProducts(ctx context.Context, skus []int64, currency string) (models.ProductResponseList, error) {
+	f.calls = append(f.calls, skus)
+	return models.ProductResponseList{}, nil
+}
+
+func newFakeProducts() *fakeProducts {
+	return &fakeProducts{Pipeline: handlers.Pipeline{Converter: fakeConverter{}}}
+}
+
+func TestBatchGetProductsRejectsUnsupportedCurrency(t *testing.T) {
+	products := newFakeProducts()
+	s := &serverAPI{products: products}
+
+	_, err := s.BatchGetProducts(context.Background(), &productv1.BatchGetProductsRequest{Skus: []int64{1}, Currency: "xyz"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	if len(products.calls) != 0 {
+		t.Errorf("expected no lookup for an unsupported currency, got %v", products.calls)
+	}
+}
+
+func TestBatchGetProductsDedupesSKUs(t *testing.T) {
+	products := newFakeProducts()
+	s := &serverAPI{products: products}
+
+	if _, err := s.BatchGetProducts(context.Background(), &productv1.BatchGetProductsRequest{Skus: []int64{3, 1, 3, 1}, Currency: "usd"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := [][]int64{{3, 1}}; !reflect.DeepEqual(products.calls, want) {
+		t.Errorf("expected lookups %v, got %v", want, products.calls)
+	}
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Riter/E-Shop/common/httperr"
+	"github.com/Riter/E-Shop/internal/warmup"
+)
+
+var errWarmupRunning = httperr.New(http.StatusConflict, "warmup_running", "cache warm-up is already running")
+
+// TriggerWarmup starts a cache warm-up in the background. Progress is
+// reported through the cache_warmup_* metrics.
+func TriggerWarmup(warmer *warmup.Warmer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := warmer.Start(); err != nil {
+			if errors.Is(err, warmup.ErrAlreadyRunning) {
+				err = errWarmupRunning
+			}
+			httperr.Write(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/Riter/E-Shop/common/httperr"
+	"github.com/Riter/E-Shop/common/codec"
 	"github.com/Riter/E-Shop/internal/models"
 )
 
@@ -20,82 +23,233 @@ type Cacher interface{
 	Set(ctx context.Context, mset map[string]string, expiration time.Duration)
 }
 
-func GetProductsWithCache(ctx context.Context, skus []string, source Source, cacher Cacher) (models.ProductResponseList, error) {
+type Converter interface {
+	Base() string
+	Currencies() []string
+	Supported(code string) bool
+	Convert(m models.Money, to string) (models.Money, error)
+}
+
+const (
+	productTTL = 5 * time.Minute
+
+	// notFoundTTL is kept short so that a product created through a path that
+	// bypasses facade-consumer becomes visible quickly anyway.
+	notFoundTTL = 30 * time.Second
+)
+
+// pendingWrites tracks cache writes GetProductsWithCache runs in the background
+// after answering, so that shutdown can wait for them.
+var pendingWrites sync.WaitGroup
+
+// WaitPendingWrites blocks until background cache writes finish or ctx is done.
+func WaitPendingWrites(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		pendingWrites.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func setAsync(ctx context.Context, cacher Cacher, mset map[string]string, expiration time.Duration) {
+	pendingWrites.Add(1)
+	go func() {
+		defer pendingWrites.Done()
+		cacher.Set(ctx, mset, expiration)
+	}()
+}
+
+// CacheKey is the cache key of a product priced in currency. Products in the
+// base currency are stored under the bare SKU, which is what facade-consumer
+// invalidates; converted prices get a currency suffix.
+func CacheKey(sku int64, currency, base string) string {
+	key := strconv.FormatInt(sku, 10)
+	if currency == base {
+		return key
+	}
+	return key + ":" + currency
+}
+
+// ConvertedKeys returns the keys of all converted variants of the product
+// cached under key.
+func ConvertedKeys(key string, currencies []string) []string {
+	keys := make([]string, len(currencies))
+	for i, currency := range currencies {
+		keys[i] = key + ":" + currency
+	}
+	return keys
+}
+
+// GetProductsWithCache returns the products priced in currency (the base one
+// if empty), reading through the cache and filling it from the source.
+func GetProductsWithCache(ctx context.Context, skus []int64, currency string, p Pipeline) (models.ProductResponseList, error) {
+	base := p.Converter.Base()
+	if currency == "" {
+		currency = base
+	}
+
 	keys := make([]string, len(skus))
 	for i, id := range skus {
-		keys[i] = id
+		keys[i] = CacheKey(id, currency, base)
 	}
 
 	
-	cached, err := cacher.Get(ctx, keys...)
+	cached, err := p.Cacher.Get(ctx, keys...)
 	if err != nil {
 		return models.ProductResponseList{}, err
 	}
 
 	var found []models.ProductResponse
 	var missedIDs []int64
+	var notFound []int64
 	mset := make(map[string]string) 
 
 	for i, val := range cached {
 		if val == nil {
-			num, _ := strconv.Atoi(skus[i])
-			missedIDs = append(missedIDs, int64(num))
+			missedIDs = append(missedIDs, skus[i])
 			continue
 		}
 
-		var p models.ProductResponse
-		if err := json.Unmarshal([]byte(val.(string)), &p); err != nil {
-			
-			return models.ProductResponseList{}, err
+		if val.(string) == models.NotFoundTombstone {
+			notFound = append(notFound, skus[i])
+			continue
 		}
-		found = append(found, p)
+
+		var product models.ProductResponse
+		if err := codec.Decode(val.(string), &product); err != nil {
+			// Entries in an outdated format are refetched and overwritten.
+			log.Printf("failed to decode cached product %s: %v", keys[i], err)
+			missedIDs = append(missedIDs, skus[i])
+			continue
+		}
+		found = append(found, product)
 	}
 
 	
 	if len(missedIDs) > 0 {
-		dbResults, err := source.GetProductsByIDs(ctx, missedIDs)
+		dbResults, err := p.Source.GetProductsByIDs(ctx, missedIDs)
 		if err != nil {
 			return models.ProductResponseList{}, err
 		}
 
-		for _, p := range dbResults {
-			found = append(found, p)
+		fetched := make(map[int64]struct{}, len(dbResults))
+		for _, product := range dbResults {
+			if err := convertPrices(&product, currency, p.Converter); err != nil {
+				return models.ProductResponseList{}, err
+			}
+			found = append(found, product)
+			fetched[int64(product.ID)] = struct{}{}
 
 			
-			raw, err := json.Marshal(p)
+			raw, err := codec.Encode(p.Codec, product)
 			if err!=nil{
 				return models.ProductResponseList{}, err
 			}
-			key := strconv.FormatInt(int64(p.ID), 10)
-			mset[key] = string(raw)
+			mset[CacheKey(int64(product.ID), currency, base)] = raw
+		}
+
+		tombstones := make(map[string]string)
+		for _, id := range missedIDs {
+			if _, ok := fetched[id]; ok {
+				continue
+			}
+			notFound = append(notFound, id)
+			tombstones[CacheKey(id, currency, base)] = models.NotFoundTombstone
 		}
 
 		
 		ctx = context.WithoutCancel(ctx)
-		log.Print("mset:", mset)
-		go cacher.Set(ctx, mset, time.Minute*5)
+		setAsync(ctx, p.Cacher, mset, productTTL)
+		if len(tombstones) > 0 {
+			setAsync(ctx, p.Cacher, tombstones, notFoundTTL)
+		}
+
+	}
+
+	return models.ProductResponseList{ProductList: found, NotFound: notFound}, nil
+}
 
+func convertPrices(product *models.ProductResponse, currency string, converter Converter) error {
+	var err error
+	product.Price, err = converter.Convert(product.Price, currency)
+	if err != nil {
+		return err
 	}
 
-	return models.ProductResponseList{ProductList: found}, nil
+	for i := range product.Variants {
+		product.Variants[i].Price, err = converter.Convert(product.Variants[i].Price, currency)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pipeline bundles the lookup dependencies so that transports other than HTTP
+// can serve products through the same cache path.
+type Pipeline struct {
+	Source    Source
+	Cacher    Cacher
+	Codec     codec.Codec
+	Converter Converter
 }
 
-func GetProducts(ctx context.Context, source Source, cacher Cacher) http.HandlerFunc {
+func (p Pipeline) GetProducts(ctx context.Context, skus []int64, currency string) (models.ProductResponseList, error) {
+	return GetProductsWithCache(ctx, skus, currency, p)
+}
+
+// Currency validates a requested currency code the same way the HTTP
+// handlers do.
+func (p Pipeline) Currency(raw string) (string, error) {
+	return ParseCurrency(raw, p.Converter)
+}
+
+// ViewTracker records which products were shown to whom.
+type ViewTracker interface {
+	Track(ctx context.Context, userID string, skus []int64)
+}
+
+// UserIDHeader carries the user's ID for view tracking and the recently
+// viewed list. facade does not authenticate it: the proxy strips it from
+// client requests until it validates tokens, so only internal callers can
+// set it.
+const UserIDHeader = "X-User-ID"
+
+func GetProducts(ctx context.Context, pipeline Pipeline, tracker ViewTracker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		
-		skus := r.URL.Query()["sku"]
-		if len(skus) == 0 {
-			http.Error(w, "missing 'sku' query parameters", http.StatusBadRequest)
+		skus, err := ParseSKUs(r.URL.Query()["sku"])
+		if err != nil {
+			httperr.Write(w, r, err)
+			return
+		}
+
+		currency, err := ParseCurrency(r.URL.Query().Get("currency"), pipeline.Converter)
+		if err != nil {
+			httperr.Write(w, r, err)
 			return
 		}
 
 		
-		result, err := GetProductsWithCache(r.Context(), skus, source, cacher)
+		result, err := GetProductsWithCache(r.Context(), skus, currency, pipeline)
 		if err != nil {
-			http.Error(w, "server error: "+err.Error(), http.StatusInternalServerError)
+			httperr.Write(w, r, err)
 			return
 		}
 
+		viewed := make([]int64, 0, len(result.ProductList))
+		for _, p := range result.ProductList {
+			viewed = append(viewed, int64(p.ID))
+		}
+		tracker.Track(r.Context(), r.Header.Get(UserIDHeader), viewed)
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(result)
 	}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Riter/E-Shop/common/codec"
+	"github.com/Riter/E-Shop/internal/models"
+)
+
+type fakeSource struct {
+	mu       sync.Mutex
+	products map[int64]models.ProductResponse
+	calls    [][]int64
+}
+
+func (s *fakeSource) GetProductsByIDs(ctx context.Context, skus []int64) ([]models.ProductResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, skus)
+	var products []models.ProductResponse
+	for _, sku := range skus {
+		if p, ok := s.products[sku]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
+
+// fakeCache keeps entries with their expiration on a clock moved by advance.
+type fakeCache struct {
+	mu      sync.Mutex
+	now     time.Time
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{
+		now:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		values:  make(map[string]string),
+		expires: make(map[string]time.Time),
+	}
+}
+
+func (c *fakeCache) Get(ctx context.Context, keys ...string) ([]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if val, ok := c.values[key]; ok && c.now.Before(c.expires[key]) {
+			result[i] = val
+		}
+	}
+	return result, nil
+}
+
+func (c *fakeCache) Set(ctx context.Context, mset map[string]string, expiration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range mset {
+		c.values[k] = v
+		c.expires[k] = c.now.Add(expiration)
+	}
+}
+
+func (c *fakeCache) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type baseConverter struct{}
+
+func (baseConverter) Base() string               { return "RUB" }
+func (baseConverter) Currencies() []string       { return nil }
+func (baseConverter) Supported(code string) bool { return code == "RUB" }
+func (baseConverter) Convert(m models.Money, to string) (models.Money, error) {
+	return m, nil
+}
+
+type noopTracker struct{}
+
+func (noopTracker) Track(ctx context.Context, userID string, skus []int64) {}
+
+func newTestPipeline(t *testing.T, source *fakeSource, cache *fakeCache) Pipeline {
+	t.Helper()
+	c, err := codec.ByName("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Pipeline{Source: source, Cacher: cache, Codec: c, Converter: baseConverter{}}
+}
+
+// getProducts runs the pipeline and waits for its background cache writes.
+func getProducts(t *testing.T, p Pipeline, skus ...int64) models.ProductResponseList {
+	t.Helper()
+	result, err := p.GetProducts(context.Background(), skus, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WaitPendingWrites(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestGetProductsWithCacheTombstone(t *testing.T) {
+	source := &fakeSource{products: map[int64]models.ProductResponse{1: {ID: 1, Name: "phone"}}}
+	cache := newFakeCache()
+	pipeline := newTestPipeline(t, source, cache)
+
+	result := getProducts(t, pipeline, 1, 2)
+	if len(result.ProductList) != 1 || !reflect.DeepEqual(result.NotFound, []int64{2}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if cache.values["2"] != models.NotFoundTombstone {
+		t.Fatalf("expected tombstone for 2, got %q", cache.values["2"])
+	}
+	if got := cache.expires["2"].Sub(cache.now); got != notFoundTTL {
+		t.Errorf("expected tombstone TTL %s, got %s", notFoundTTL, got)
+	}
+
+	// the deleted SKU is answered from the tombstone without Postgres
+	result = getProducts(t, pipeline, 1, 2)
+	if len(result.ProductList) != 1 || !reflect.DeepEqual(result.NotFound, []int64{2}) {
+		t.Errorf("unexpected cached result %+v", result)
+	}
+	if len(source.calls) != 1 {
+		t.Errorf("expected one source read, got %v", source.calls)
+	}
+
+	// once the tombstone expires, a product created meanwhile is found
+	source.products[2] = models.ProductResponse{ID: 2, Name: "case"}
+	cache.advance(notFoundTTL + time.Second)
+	result = getProducts(t, pipeline, 2)
+	if len(result.ProductList) != 1 || len(result.NotFound) != 0 {
+		t.Errorf("expected product 2 after tombstone expiry, got %+v", result)
+	}
+	if want := [][]int64{{1, 2}, {2}}; !reflect.DeepEqual(source.calls, want) {
+		t.Errorf("expected source reads %v, got %v", want, source.calls)
+	}
+}
+
+func TestGetProductsReportsDeletedSKU(t *testing.T) {
+	source := &fakeSource{}
+	cache := newFakeCache()
+	cache.Set(context.Background(), map[string]string{"7": models.NotFoundTombstone}, notFoundTTL)
+	handler := GetProducts(context.Background(), newTestPipeline(t, source, cache), noopTracker{})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/products?sku=7", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body struct {
+		NotFound []int64 `json:"not_found"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !reflect.DeepEqual(body.NotFound, []int64{7}) {
+		t.Errorf("expected 7 in not_found, got %v", body.NotFound)
+	}
+	if len(source.calls) != 0 {
+		t.Errorf("expected no source reads, got %v", source.calls)
+	}
+}
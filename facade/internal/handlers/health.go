@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports whether a dependency is reachable.
+type Check func(ctx context.Context) error
+
+const readinessTimeout = 2 * time.Second
+
+var shuttingDown atomic.Bool
+
+// MarkShuttingDown makes /readyz fail so load balancers stop routing new
+// requests while in-flight ones are drained.
+func MarkShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// Healthz reports liveness: the process is up and serving HTTP.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+// Readyz runs all checks concurrently, each bounded by readinessTimeout, and
+// responds 503 if any of them fails or the service is shutting down. The
+// endpoint is unauthenticated, so failures are reported as "unavailable" and
+// the error itself, which may name hosts or DSNs, only goes to the log.
+func Readyz(checks map[string]Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := make(map[string]string, len(checks))
+		ready := !shuttingDown.Load()
+		if !ready {
+			status["server"] = "shutting down"
+		}
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for name, check := range checks {
+			wg.Add(1)
+			go func(name string, check Check) {
+				defer wg.Done()
+
+				ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+				defer cancel()
+
+				result := "ok"
+				if err := check(ctx); err != nil {
+					log.Printf("readiness check %s failed: %v", name, err)
+					result = "unavailable"
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				if result != "ok" {
+					ready = false
+				}
+				status[name] = result
+			}(name, check)
+		}
+		wg.Wait()
+
+		w.Header().Set("Content-Type", "application/json")
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestReadyzHidesCheckErrors(t *testing.T) {
+	handler := Readyz(map[string]Check{
+		"redis":    func(ctx context.Context) error { return nil },
+		"postgres": func(ctx context.Context) error { return errors.New("dial tcp db.internal:5432: connection refused") },
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	var status map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := map[string]string{"redis": "ok", "postgres": "unavailable"}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("expected %v, got %v", want, status)
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"strconv"
+)
+
+type LocalInvalidator interface {
+	Invalidate(key string)
+}
+
+type KeyDeleter interface {
+	Del(ctx context.Context, keys ...string) error
+}
+
+// ProductInvalidator handles a message from the invalidation channel.
+// facade-consumer only deletes or rewrites the base-currency key in Redis, so
+// the converted variants are deleted here; every replica does it, which is
+// harmless since DEL is idempotent.
+func ProductInvalidator(ctx context.Context, local LocalInvalidator, remote KeyDeleter, converter Converter) func(key string) {
+	return func(key string) {
+		converted := ConvertedKeys(key, converter.Currencies())
+
+		local.Invalidate(key)
+		for _, k := range converted {
+			local.Invalidate(k)
+		}
+
+		if err := remote.Del(ctx, converted...); err != nil {
+			log.Printf("failed to delete converted keys of %s: %v", key, err)
+		}
+	}
+}
+
+// ProductChangeInvalidator handles a change of a single variant or attribute.
+// The cache is keyed by product, so the whole product entry is dropped from
+// Redis and from the local tier. Every replica receives the notification
+// from Postgres itself.
+func ProductChangeInvalidator(ctx context.Context, local LocalInvalidator, remote KeyDeleter, converter Converter) func(productID int64, change string) {
+	invalidate := ProductInvalidator(ctx, local, remote, converter)
+
+	return func(productID int64, change string) {
+		key := strconv.FormatInt(productID, 10)
+		if err := remote.Del(ctx, key); err != nil {
+			log.Printf("failed to invalidate product %s after change of %s: %v", key, change, err)
+		}
+		invalidate(key)
+	}
+}
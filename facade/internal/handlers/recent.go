@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Riter/E-Shop/common/httperr"
+	"github.com/Riter/E-Shop/internal/models"
+)
+
+var errMissingUserID = httperr.New(http.StatusUnauthorized, "missing_user_id", UserIDHeader+" header is required")
+
+type RecentStore interface {
+	RecentlyViewed(ctx context.Context, userID string, limit int) ([]int64, error)
+}
+
+// RecentlyViewed serves the products the current user viewed last, newest
+// first. Products deleted since are left out.
+func RecentlyViewed(store RecentStore, limit int, pipeline Pipeline) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(UserIDHeader)
+		if userID == "" {
+			httperr.Write(w, r, errMissingUserID)
+			return
+		}
+
+		currency, err := ParseCurrency(r.URL.Query().Get("currency"), pipeline.Converter)
+		if err != nil {
+			httperr.Write(w, r, err)
+			return
+		}
+
+		skus, err := store.RecentlyViewed(r.Context(), userID, limit)
+		if err != nil {
+			httperr.Write(w, r, err)
+			return
+		}
+
+		products := []models.ProductResponse{}
+		if len(skus) > 0 {
+			result, err := GetProductsWithCache(r.Context(), skus, currency, pipeline)
+			if err != nil {
+				httperr.Write(w, r, err)
+				return
+			}
+
+			byID := make(map[int64]models.ProductResponse, len(result.ProductList))
+			for _, p := range result.ProductList {
+				byID[int64(p.ID)] = p
+			}
+			for _, sku := range skus {
+				if p, ok := byID[sku]; ok {
+					products = append(products, p)
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.ProductResponseList{ProductList: products})
+	}
+}
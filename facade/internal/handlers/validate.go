@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Riter/E-Shop/common/httperr"
+)
+
+// MaxBatchSize caps the number of SKUs a single request may ask for.
+const MaxBatchSize = 100
+
+var (
+	errMissingSKU   = httperr.BadRequest("missing_sku", "at least one 'sku' query parameter is required")
+	errInvalidSKU   = httperr.BadRequest("invalid_sku", "sku must be a positive integer")
+	errBatchTooLong = httperr.BadRequest("batch_too_large", fmt.Sprintf("at most %d skus can be requested at once", MaxBatchSize))
+
+	errUnsupportedCurrency = httperr.BadRequest("unsupported_currency", "currency is not supported")
+)
+
+// ParseSKUs validates raw sku values and returns them as IDs, keeping the
+// request order and dropping duplicates.
+func ParseSKUs(raw []string) ([]int64, error) {
+	if len(raw) == 0 {
+		return nil, errMissingSKU
+	}
+	if len(raw) > MaxBatchSize {
+		return nil, errBatchTooLong.WithDetails(map[string]int{"max": MaxBatchSize, "got": len(raw)})
+	}
+
+	skus := make([]int64, 0, len(raw))
+	seen := make(map[int64]struct{}, len(raw))
+	var invalid []string
+	for _, s := range raw {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 {
+			invalid = append(invalid, s)
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		skus = append(skus, id)
+	}
+
+	if len(invalid) > 0 {
+		return nil, errInvalidSKU.WithDetails(map[string][]string{"invalid_skus": invalid})
+	}
+	return skus, nil
+}
+
+// ParseCurrency normalizes an optional ISO 4217 code; empty means the base currency.
+func ParseCurrency(raw string, converter Converter) (string, error) {
+	if raw == "" {
+		return converter.Base(), nil
+	}
+
+	code := strings.ToUpper(raw)
+	if !converter.Supported(code) {
+		return "", errUnsupportedCurrency.WithDetails(map[string]string{"currency": raw})
+	}
+	return code, nil
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/Riter/E-Shop/common/httperr"
+)
+
+func TestParseSKUs(t *testing.T) {
+	skus, err := ParseSKUs([]string{"3", "1", "3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(skus, []int64{3, 1}) {
+		t.Errorf("expected [3 1], got %v", skus)
+	}
+}
+
+func TestParseSKUsInvalid(t *testing.T) {
+	_, err := ParseSKUs([]string{"1", "abc", "-2", "0"})
+
+	var apiErr *httperr.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != "invalid_sku" {
+		t.Fatalf("expected invalid_sku error, got %v", err)
+	}
+	want := map[string][]string{"invalid_skus": {"abc", "-2", "0"}}
+	if !reflect.DeepEqual(apiErr.Details, want) {
+		t.Errorf("expected details %v, got %v", want, apiErr.Details)
+	}
+}
+
+func TestParseSKUsBatchTooLarge(t *testing.T) {
+	raw := make([]string, MaxBatchSize+1)
+	for i := range raw {
+		raw[i] = strconv.Itoa(i + 1)
+	}
+
+	var apiErr *httperr.Error
+	if _, err := ParseSKUs(raw); !errors.As(err, &apiErr) || apiErr.Code != "batch_too_large" {
+		t.Fatalf("expected batch_too_large error, got %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+// Money is an exact amount in the currency given by its ISO 4217 code.
+// Amount is encoded as a JSON string to keep its precision.
+type Money struct {
+	Amount   decimal.Decimal `json:"amount"`
+	Currency string          `json:"currency"`
+}
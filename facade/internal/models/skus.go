@@ -2,19 +2,26 @@ package models
 
 import "time"
 
+// NotFoundTombstone is cached in place of a product that is missing in
+// Postgres, so repeated lookups of unknown SKUs don't reach the database.
+const NotFoundTombstone = "__not_found__"
+
 type ProductResponse struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	Category    string    `json:"category"`
-	CreatedAt   time.Time `json:"created_at"`
-	Images      []string  `json:"images"`
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       Money       `json:"price"`
+	Category    string      `json:"category"`
+	CreatedAt   time.Time   `json:"created_at"`
+	Images      []string    `json:"images"`
+	Attributes  []Attribute `json:"attributes"`
+	Variants    []Variant   `json:"variants"`
 }
 
 
 type ProductResponseList struct {
 	ProductList []ProductResponse `json:"product_list"`
+	NotFound    []int64           `json:"not_found"`
 }
 
 
@@ -0,0 +1,22 @@
+package models
+
+// Attribute is a typed key/value characteristic of a product. Type is one of
+// "string", "number" or "bool"; Value keeps its textual form.
+type Attribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// Variant is a purchasable option of a product, such as a size or colour,
+// with its own SKU and stock. Price is the effective price: the variant's
+// override or, if it has none, the product's price. InheritsPrice is set in
+// the latter case, so that a cached product can be repriced without Postgres.
+type Variant struct {
+	ID            int               `json:"id"`
+	SKU           string            `json:"sku"`
+	Price         Money             `json:"price"`
+	InheritsPrice bool              `json:"inherits_price"`
+	Stock         int               `json:"stock"`
+	Attributes    map[string]string `json:"attributes"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+const EventProductViewed = "product_viewed"
+
+// ProductView is the product_viewed event facade publishes for every product
+// it serves. UserID is empty for anonymous requests.
+type ProductView struct {
+	Type     string    `json:"type"`
+	UserID   string    `json:"user_id,omitempty"`
+	SKU      int64     `json:"sku"`
+	ViewedAt time.Time `json:"viewed_at"`
+	TraceID  string    `json:"trace_id,omitempty"`
+}
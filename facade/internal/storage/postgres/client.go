@@ -12,6 +12,8 @@ import (
 
 type Postgres struct {
     DB *sql.DB
+
+    priceCurrency string
 }
 
 func NewPostgres(ctx context.Context, cfg config.PostgresConfig) (*Postgres, error) {
@@ -28,10 +30,18 @@ func NewPostgres(ctx context.Context, cfg config.PostgresConfig) (*Postgres, err
         return nil, fmt.Errorf("failed to ping postgres: %w", err)
     }
 
-    return &Postgres{DB: db}, nil
+    return &Postgres{DB: db, priceCurrency: cfg.PriceCurrency}, nil
 }
 
 
 func (p *Postgres) GetStorage() *sql.DB{
 	return p.DB
 }
+
+func (p *Postgres) Ping(ctx context.Context) error {
+	return p.DB.PingContext(ctx)
+}
+
+func (p *Postgres) Close() error {
+	return p.DB.Close()
+}
@@ -0,0 +1,83 @@
+package psq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Riter/E-Shop/internal/config"
+	pq "github.com/lib/pq"
+)
+
+// Channels notified by the product_variants and product_attributes triggers.
+const (
+	variantChangesChannel   = "product_variant_changes"
+	attributeChangesChannel = "product_attribute_changes"
+)
+
+var changeChannels = []string{variantChangesChannel, attributeChangesChannel}
+
+// ProductChange is a changed row of a table that is part of the cached
+// product: a variant (SKU is set) or an attribute (Key is set).
+type ProductChange struct {
+	ProductID int64  `json:"product_id"`
+	SKU       string `json:"sku,omitempty"`
+	Key       string `json:"key,omitempty"`
+}
+
+func (c ProductChange) String() string {
+	if c.SKU != "" {
+		return "variant " + c.SKU
+	}
+	return "attribute " + c.Key
+}
+
+// ListenProductChanges calls handle for every inserted, updated or deleted
+// product variant or attribute until ctx is done. Notifications sent while
+// the listener is reconnecting are lost; cache TTLs bound the staleness in
+// that case.
+func ListenProductChanges(ctx context.Context, cfg config.PostgresConfig, handle func(productID int64, change string)) error {
+	listener := pq.NewListener(cfg.DSN(), 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("postgres listener event %d: %v", ev, err)
+		}
+	})
+	defer listener.Close()
+
+	for _, channel := range changeChannels {
+		if err := listener.Listen(channel); err != nil {
+			return fmt.Errorf("listen %s: %w", channel, err)
+		}
+	}
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ping.C:
+			go listener.Ping()
+		case n := <-listener.Notify:
+			dispatch(n, handle)
+		}
+	}
+}
+
+// dispatch decodes a notification and passes it to handle. A nil
+// notification is sent by pq after a reconnect and carries no change.
+func dispatch(n *pq.Notification, handle func(productID int64, change string)) {
+	if n == nil {
+		return
+	}
+
+	var change ProductChange
+	if err := json.Unmarshal([]byte(n.Extra), &change); err != nil || change.ProductID <= 0 {
+		log.Printf("invalid %s notification %q: %v", n.Channel, n.Extra, err)
+		return
+	}
+	handle(change.ProductID, change.String())
+}
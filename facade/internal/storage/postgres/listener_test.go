@@ -0,0 +1,61 @@
+package psq
+
+import (
+	"slices"
+	"testing"
+
+	pq "github.com/lib/pq"
+)
+
+func TestListenerSubscribesToAttributeChanges(t *testing.T) {
+	if !slices.Contains(changeChannels, attributeChangesChannel) {
+		t.Fatalf("listener does not subscribe to %s", attributeChangesChannel)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		n          *pq.Notification
+		wantID     int64
+		wantChange string
+	}{
+		{
+			name:       "variant",
+			n:          &pq.Notification{Channel: variantChangesChannel, Extra: `{"product_id": 1, "sku": "IPH13-128-BLK"}`},
+			wantID:     1,
+			wantChange: "variant IPH13-128-BLK",
+		},
+		{
+			name:       "attribute",
+			n:          &pq.Notification{Channel: attributeChangesChannel, Extra: `{"product_id": 5, "key": "battery_hours"}`},
+			wantID:     5,
+			wantChange: "attribute battery_hours",
+		},
+		{name: "reconnect", n: nil},
+		{name: "invalid payload", n: &pq.Notification{Channel: attributeChangesChannel, Extra: `not json`}},
+		{name: "missing product", n: &pq.Notification{Channel: attributeChangesChannel, Extra: `{"key": "color"}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotID int64
+			var gotChange string
+			calls := 0
+			dispatch(tt.n, func(productID int64, change string) {
+				calls++
+				gotID, gotChange = productID, change
+			})
+
+			if tt.wantID == 0 {
+				if calls != 0 {
+					t.Fatalf("expected no call, got product %d", gotID)
+				}
+				return
+			}
+			if calls != 1 || gotID != tt.wantID || gotChange != tt.wantChange {
+				t.Errorf("expected one call with (%d, %q), got %d calls with (%d, %q)", tt.wantID, tt.wantChange, calls, gotID, gotChange)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package psq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+func (p *Postgres) GetCurrencyRates(ctx context.Context) (map[string]decimal.Decimal, error) {
+	rows, err := p.DB.QueryContext(ctx, `SELECT currency, rate FROM currency_rates`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := make(map[string]decimal.Decimal)
+	for rows.Next() {
+		var code string
+		var rate decimal.Decimal
+		if err := rows.Scan(&code, &rate); err != nil {
+			return nil, err
+		}
+		rates[code] = rate
+	}
+
+	return rates, rows.Err()
+}
+
+func (p *Postgres) UpsertCurrencyRates(ctx context.Context, rates map[string]decimal.Decimal) error {
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for code, rate := range rates {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO currency_rates (currency, rate, updated_at)
+			VALUES ($1, $2, NOW())
+			ON CONFLICT (currency) DO UPDATE SET rate = EXCLUDED.rate, updated_at = EXCLUDED.updated_at
+		`, code, rate)
+		if err != nil {
+			return fmt.Errorf("upsert rate %s: %w", code, err)
+		}
+	}
+
+	return tx.Commit()
+}
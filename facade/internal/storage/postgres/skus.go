@@ -6,8 +6,17 @@ import (
 
 	"github.com/Riter/E-Shop/internal/models"
 	pq "github.com/lib/pq"
+	"github.com/shopspring/decimal"
 )
 
+type variantRow struct {
+    ID         int               `json:"id"`
+    SKU        string            `json:"sku"`
+    Price      *decimal.Decimal  `json:"price"`
+    Stock      int               `json:"stock"`
+    Attributes map[string]string `json:"attributes"`
+}
+
 func (p *Postgres) GetProductsByIDs(ctx context.Context, skus []int64) ([]models.ProductResponse, error) {
     query := `
         SELECT
@@ -17,11 +26,23 @@ func (p *Postgres) GetProductsByIDs(ctx context.Context, skus []int64) ([]models
             p.price,
             p.category,
             p.created_at,
-            COALESCE(json_agg(pi.image_url) FILTER (WHERE pi.image_url IS NOT NULL), '[]') AS images
+            COALESCE((
+                SELECT json_agg(pi.image_url ORDER BY pi.id)
+                FROM product_images pi
+                WHERE pi.product_id = p.id
+            ), '[]') AS images,
+            COALESCE((
+                SELECT json_agg(json_build_object('key', pa.key, 'value', pa.value, 'type', pa.value_type) ORDER BY pa.key)
+                FROM product_attributes pa
+                WHERE pa.product_id = p.id
+            ), '[]') AS attributes,
+            COALESCE((
+                SELECT json_agg(json_build_object('id', pv.id, 'sku', pv.sku, 'price', pv.price, 'stock', pv.stock, 'attributes', pv.attributes) ORDER BY pv.id)
+                FROM product_variants pv
+                WHERE pv.product_id = p.id
+            ), '[]') AS variants
         FROM products p
-        LEFT JOIN product_images pi ON p.id = pi.product_id
-        WHERE p.id = ANY($1)
-        GROUP BY p.id;
+        WHERE p.id = ANY($1);
     `
 
     rows, err := p.DB.QueryContext(ctx, query, pq.Array(skus))
@@ -31,23 +52,61 @@ func (p *Postgres) GetProductsByIDs(ctx context.Context, skus []int64) ([]models
     defer rows.Close()
 
     var products []models.ProductResponse
+    priceCurrency := p.priceCurrency
 
     for rows.Next() {
         var p models.ProductResponse
-        var imagesRaw []byte
+        var imagesRaw, attributesRaw, variantsRaw []byte
 
-        err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Category, &p.CreatedAt, &imagesRaw)
+        err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price.Amount, &p.Category, &p.CreatedAt, &imagesRaw, &attributesRaw, &variantsRaw)
         if err != nil {
             return nil, err
         }
+        p.Price.Currency = priceCurrency
 
         
         if err := json.Unmarshal(imagesRaw, &p.Images); err != nil {
             return nil, err
         }
 
+        if err := json.Unmarshal(attributesRaw, &p.Attributes); err != nil {
+            return nil, err
+        }
+
+        var variants []variantRow
+        if err := json.Unmarshal(variantsRaw, &variants); err != nil {
+            return nil, err
+        }
+        p.Variants = make([]models.Variant, len(variants))
+        for i, v := range variants {
+            price := p.Price
+            if v.Price != nil {
+                price.Amount = *v.Price
+            }
+            p.Variants[i] = models.Variant{ID: v.ID, SKU: v.SKU, Price: price, InheritsPrice: v.Price == nil, Stock: v.Stock, Attributes: v.Attributes}
+        }
+
         products = append(products, p)
     }
 
     return products, nil
 }
+
+// NewestProductIDs returns the IDs of the n most recently created products.
+func (p *Postgres) NewestProductIDs(ctx context.Context, n int) ([]int64, error) {
+    rows, err := p.DB.QueryContext(ctx, `SELECT id FROM products ORDER BY created_at DESC, id DESC LIMIT $1`, n)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    ids := make([]int64, 0, n)
+    for rows.Next() {
+        var id int64
+        if err := rows.Scan(&id); err != nil {
+            return nil, err
+        }
+        ids = append(ids, id)
+    }
+    return ids, rows.Err()
+}
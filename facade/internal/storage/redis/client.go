@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/Riter/E-Shop/internal/config"
+	"github.com/Riter/E-Shop/internal/models"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
 )
@@ -130,7 +132,7 @@ func (r *RedisImpl) Set(ctx context.Context, mset map[string]string, expiration
 		RedisSetPipelineLength.Observe(float64(len(mset)))
 		pipe := r.storage.Pipeline()
 		for k, v := range mset {
-			pipe.Set(ctx, k, v, 5*time.Minute)
+			pipe.Set(ctx, k, v, expiration)
 		}
 		res, err := pipe.Exec(ctx)
 		SetValuesCount.Add(float64(len(mset)))
@@ -139,4 +141,128 @@ func (r *RedisImpl) Set(ctx context.Context, mset map[string]string, expiration
 			RedisSetErrors.Inc()
 		}
 	}
-}
\ No newline at end of file
+}
+
+// ViewsKey is the sorted set holding per-product view counts.
+const ViewsKey = "product:views"
+
+// TopViewed returns up to n SKUs with the highest view counts.
+func (r *RedisImpl) TopViewed(ctx context.Context, n int) ([]int64, error) {
+	members, err := r.storage.ZRevRange(ctx, ViewsKey, 0, int64(n-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	skus := make([]int64, 0, len(members))
+	for _, m := range members {
+		sku, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		skus = append(skus, sku)
+	}
+	return skus, nil
+}
+
+func (r *RedisImpl) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.storage.Del(ctx, keys...).Err()
+}
+
+func (r *RedisImpl) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := r.storage.Exists(ctx, key).Result()
+	return n > 0, err
+}
+
+// unlockScript deletes the lock only if it still holds the caller's token, so
+// a holder whose lock expired cannot release someone else's.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Lock takes key for ttl unless another holder has it. It reports whether the
+// lock was acquired.
+func (r *RedisImpl) Lock(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return r.storage.SetNX(ctx, key, token, ttl).Result()
+}
+
+// Unlock releases a lock taken with token.
+func (r *RedisImpl) Unlock(ctx context.Context, key, token string) error {
+	return unlockScript.Run(ctx, r.storage, []string{key}, token).Err()
+}
+
+// SetPersistent stores key without expiration.
+func (r *RedisImpl) SetPersistent(ctx context.Context, key, value string) error {
+	return r.storage.Set(ctx, key, value, 0).Err()
+}
+
+// Subscribe calls handle for every message published to channel until ctx is done.
+func (r *RedisImpl) Subscribe(ctx context.Context, channel string, handle func(payload string)) {
+	sub := r.storage.Subscribe(ctx, channel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			handle(msg.Payload)
+		}
+	}
+}
+
+// RecentlyViewedKey is the list of SKUs userID viewed last, newest first.
+func RecentlyViewedKey(userID string) string {
+	return "user:" + userID + ":recently_viewed"
+}
+
+// RecordViews bumps the view counters and moves each viewed SKU to the head
+// of its user's recently viewed list, which is capped at limit entries.
+func (r *RedisImpl) RecordViews(ctx context.Context, views []models.ProductView, limit int, ttl time.Duration) error {
+	if len(views) == 0 {
+		return nil
+	}
+
+	pipe := r.storage.Pipeline()
+	for _, v := range views {
+		sku := strconv.FormatInt(v.SKU, 10)
+		pipe.ZIncrBy(ctx, ViewsKey, 1, sku)
+
+		if v.UserID == "" {
+			continue
+		}
+		key := RecentlyViewedKey(v.UserID)
+		pipe.LRem(ctx, key, 0, sku)
+		pipe.LPush(ctx, key, sku)
+		pipe.LTrim(ctx, key, 0, int64(limit-1))
+		pipe.Expire(ctx, key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RecentlyViewed returns up to limit SKUs userID viewed last, newest first.
+func (r *RedisImpl) RecentlyViewed(ctx context.Context, userID string, limit int) ([]int64, error) {
+	members, err := r.storage.LRange(ctx, RecentlyViewedKey(userID), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	skus := make([]int64, 0, len(members))
+	for _, m := range members {
+		sku, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		skus = append(skus, sku)
+	}
+	return skus, nil
+}
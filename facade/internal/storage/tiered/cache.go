@@ -0,0 +1,143 @@
+package tiered
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	prometheus.MustRegister(CacheTierHits, CacheTierMisses)
+}
+
+const (
+	tierLocal = "local"
+	tierRedis = "redis"
+)
+
+var (
+	CacheTierHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_tier_hits_total",
+		Help: "number of cache hits per tier",
+	}, []string{"tier"})
+
+	CacheTierMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_tier_misses_total",
+		Help: "number of cache misses per tier",
+	}, []string{"tier"})
+)
+
+// Remote is the shared cache sitting behind the in-process tier.
+type Remote interface {
+	Get(ctx context.Context, keys ...string) ([]interface{}, error)
+	Set(ctx context.Context, mset map[string]string, expiration time.Duration)
+}
+
+// Cache keeps a small, size-bounded LRU in front of Remote so that hot keys
+// are served without a Redis round trip. Entries live in the local tier for
+// at most ttl, and no longer than the expiration they were Set with;
+// cross-replica invalidation is done through Invalidate.
+type Cache struct {
+	local  *expirable.LRU[string, entry]
+	remote Remote
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// entry is a locally cached value; the LRU expires all entries after the same
+// ttl, so shorter lifetimes are checked against expires on read. Zero expires
+// means the entry stays until the LRU drops it.
+type entry struct {
+	value   string
+	expires time.Time
+}
+
+func New(remote Remote, size int, ttl time.Duration) *Cache {
+	return &Cache{
+		local:  expirable.NewLRU[string, entry](size, nil, ttl),
+		remote: remote,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+func (c *Cache) Get(ctx context.Context, keys ...string) ([]interface{}, error) {
+	result := make([]interface{}, len(keys))
+
+	var missedKeys []string
+	var missedIdx []int
+	now := c.now()
+	for i, key := range keys {
+		if e, ok := c.local.Get(key); ok {
+			if e.expires.IsZero() || now.Before(e.expires) {
+				result[i] = e.value
+				continue
+			}
+			c.local.Remove(key)
+		}
+		missedKeys = append(missedKeys, key)
+		missedIdx = append(missedIdx, i)
+	}
+
+	CacheTierHits.WithLabelValues(tierLocal).Add(float64(len(keys) - len(missedKeys)))
+	CacheTierMisses.WithLabelValues(tierLocal).Add(float64(len(missedKeys)))
+
+	if len(missedKeys) == 0 {
+		return result, nil
+	}
+
+	remote, err := c.remote.Get(ctx, missedKeys...)
+	if err != nil {
+		return nil, err
+	}
+
+	var hits int
+	for j, val := range remote {
+		str, ok := val.(string)
+		if !ok {
+			continue
+		}
+		hits++
+		result[missedIdx[j]] = str
+		c.local.Add(missedKeys[j], entry{value: str, expires: c.expiry(now, 0)})
+	}
+
+	CacheTierHits.WithLabelValues(tierRedis).Add(float64(hits))
+	CacheTierMisses.WithLabelValues(tierRedis).Add(float64(len(missedKeys) - hits))
+
+	return result, nil
+}
+
+// Set writes mset to Remote with expiration and keeps it locally for at most
+// min(expiration, ttl), so a short-lived entry such as a not-found tombstone
+// doesn't outlive its Redis copy. Zero expiration means the Redis entry
+// doesn't expire.
+func (c *Cache) Set(ctx context.Context, mset map[string]string, expiration time.Duration) {
+	c.remote.Set(ctx, mset, expiration)
+
+	expires := c.expiry(c.now(), expiration)
+	for k, v := range mset {
+		c.local.Add(k, entry{value: v, expires: expires})
+	}
+}
+
+// expiry returns when a local entry stored at now expires: after ttl, or
+// after expiration if that is shorter. Non-positive durations don't limit it.
+func (c *Cache) expiry(now time.Time, expiration time.Duration) time.Time {
+	lifetime := c.ttl
+	if expiration > 0 && (lifetime <= 0 || expiration < lifetime) {
+		lifetime = expiration
+	}
+	if lifetime <= 0 {
+		return time.Time{}
+	}
+	return now.Add(lifetime)
+}
+
+// Invalidate drops key from the local tier only; Redis is expected to be
+// cleaned up by whoever published the invalidation.
+func (c *Cache) Invalidate(key string) {
+	c.local.Remove(key)
+}
@@ -0,0 +1,163 @@
+package tiered
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeRemote struct {
+	values map[string]string
+	gets   [][]string
+}
+
+func newFakeRemote(values map[string]string) *fakeRemote {
+	if values == nil {
+		values = make(map[string]string)
+	}
+	return &fakeRemote{values: values}
+}
+
+func (r *fakeRemote) Get(ctx context.Context, keys ...string) ([]interface{}, error) {
+	r.gets = append(r.gets, keys)
+	result := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if val, ok := r.values[key]; ok {
+			result[i] = val
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeRemote) Set(ctx context.Context, mset map[string]string, expiration time.Duration) {
+	for k, v := range mset {
+		r.values[k] = v
+	}
+}
+
+// newTestCache returns a cache whose clock is moved by advancing *now.
+func newTestCache(remote Remote, ttl time.Duration) (*Cache, *time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New(remote, 16, ttl)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestCacheLocalHitSkipsRemote(t *testing.T) {
+	remote := newFakeRemote(nil)
+	c, _ := newTestCache(remote, time.Minute)
+	c.Set(context.Background(), map[string]string{"1": "product"}, time.Hour)
+
+	got, err := c.Get(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != "product" {
+		t.Errorf("expected local value, got %v", got)
+	}
+	if len(remote.gets) != 0 {
+		t.Errorf("expected no remote reads, got %v", remote.gets)
+	}
+}
+
+func TestCacheMissFillsLocal(t *testing.T) {
+	remote := newFakeRemote(map[string]string{"2": "product"})
+	c, _ := newTestCache(remote, time.Minute)
+
+	for range 2 {
+		got, err := c.Get(context.Background(), "2", "3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, []interface{}{"product", nil}) {
+			t.Errorf("unexpected values %v", got)
+		}
+	}
+	// "2" is read from Redis once; "3" is missing everywhere and is asked for
+	// both times
+	if want := [][]string{{"2", "3"}, {"3"}}; !reflect.DeepEqual(remote.gets, want) {
+		t.Errorf("expected remote reads %v, got %v", want, remote.gets)
+	}
+}
+
+func TestCacheInvalidateDropsLocalOnly(t *testing.T) {
+	remote := newFakeRemote(nil)
+	c, _ := newTestCache(remote, time.Minute)
+	c.Set(context.Background(), map[string]string{"1": "product"}, time.Hour)
+
+	c.Invalidate("1")
+
+	got, err := c.Get(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != "product" || len(remote.gets) != 1 {
+		t.Errorf("expected value re-read from remote, got %v after reads %v", got, remote.gets)
+	}
+}
+
+func TestCacheSetCapsLocalLifetime(t *testing.T) {
+	tests := []struct {
+		name       string
+		ttl        time.Duration
+		expiration time.Duration
+		after      time.Duration
+		wantLocal  bool
+	}{
+		{"short expiration caps local copy", time.Minute, 30 * time.Second, 31 * time.Second, false},
+		{"short expiration still valid", time.Minute, 30 * time.Second, 29 * time.Second, true},
+		{"local ttl shorter than expiration", 10 * time.Second, time.Hour, 11 * time.Second, false},
+		{"no expiration uses local ttl", time.Minute, 0, 59 * time.Second, true},
+		{"no local ttl uses expiration", 0, 30 * time.Second, 31 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := newFakeRemote(nil)
+			c, now := newTestCache(remote, tt.ttl)
+			c.Set(context.Background(), map[string]string{"1": "product"}, tt.expiration)
+
+			*now = now.Add(tt.after)
+			if _, err := c.Get(context.Background(), "1"); err != nil {
+				t.Fatal(err)
+			}
+			if gotLocal := len(remote.gets) == 0; gotLocal != tt.wantLocal {
+				t.Errorf("expected local hit %v, got remote reads %v", tt.wantLocal, remote.gets)
+			}
+		})
+	}
+}
+
+func TestCacheTierCounters(t *testing.T) {
+	remote := newFakeRemote(map[string]string{"2": "product"})
+	c, _ := newTestCache(remote, time.Minute)
+	c.Set(context.Background(), map[string]string{"1": "product"}, time.Hour)
+
+	counters := []struct {
+		name string
+		get  func() float64
+		want float64
+	}{
+		{"local hits", func() float64 { return testutil.ToFloat64(CacheTierHits.WithLabelValues(tierLocal)) }, 1},
+		{"local misses", func() float64 { return testutil.ToFloat64(CacheTierMisses.WithLabelValues(tierLocal)) }, 2},
+		{"redis hits", func() float64 { return testutil.ToFloat64(CacheTierHits.WithLabelValues(tierRedis)) }, 1},
+		{"redis misses", func() float64 { return testutil.ToFloat64(CacheTierMisses.WithLabelValues(tierRedis)) }, 1},
+	}
+	before := make([]float64, len(counters))
+	for i, counter := range counters {
+		before[i] = counter.get()
+	}
+
+	if _, err := c.Get(context.Background(), "1", "2", "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, counter := range counters {
+		if got := counter.get() - before[i]; got != counter.want {
+			t.Errorf("%s: expected +%v, got +%v", counter.name, counter.want, got)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package views
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/Riter/E-Shop/internal/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher writes product_viewed events keyed by SKU, so that views of
+// one product land in one partition. Tracker already batches, so the writer
+// is tuned to send what it gets right away.
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaPublisher(brokers []string, topic string, batchSize int) *KafkaPublisher {
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireOne,
+			BatchSize:    batchSize,
+			BatchTimeout: 10 * time.Millisecond,
+		},
+	}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, views []models.ProductView) error {
+	msgs := make([]kafka.Message, 0, len(views))
+	for _, v := range views {
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, kafka.Message{Key: []byte(strconv.FormatInt(v.SKU, 10)), Value: value})
+	}
+	return p.writer.WriteMessages(ctx, msgs...)
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
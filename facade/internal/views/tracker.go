@@ -0,0 +1,173 @@
+package views
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Riter/E-Shop/internal/config"
+	"github.com/Riter/E-Shop/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func init() {
+	prometheus.MustRegister(ViewsTracked, ViewsDropped, ViewsFlushErrors)
+}
+
+var (
+	ViewsTracked = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "product_views_tracked_total",
+		Help: "number of product views accepted for recording",
+	})
+
+	ViewsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "product_views_dropped_total",
+		Help: "number of product views dropped because the queue was full",
+	})
+
+	ViewsFlushErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "product_views_flush_errors_total",
+		Help: "number of failed view batch flushes by sink",
+	}, []string{"sink"})
+)
+
+// Publisher sends product_viewed events to the analytics pipeline.
+type Publisher interface {
+	Publish(ctx context.Context, views []models.ProductView) error
+}
+
+// Store keeps view counters and recently viewed lists.
+type Store interface {
+	RecordViews(ctx context.Context, views []models.ProductView, limit int, ttl time.Duration) error
+}
+
+// Tracker records product views off the request path. Track only enqueues;
+// a single goroutine flushes the queue in batches to Redis and Kafka. When
+// the queue is full views are dropped rather than slowing down responses.
+type Tracker struct {
+	cfg       *config.ViewsConfig
+	publisher Publisher
+	store     Store
+
+	// mu guards closed: Track holds it for reading while enqueuing, so Close
+	// cannot close the queue under an in-flight handler.
+	mu     sync.RWMutex
+	closed bool
+	queue  chan models.ProductView
+	done   chan struct{}
+}
+
+// NewTracker returns a Tracker; publisher may be nil to skip Kafka.
+func NewTracker(cfg *config.ViewsConfig, publisher Publisher, store Store) *Tracker {
+	return &Tracker{
+		cfg:       cfg,
+		publisher: publisher,
+		store:     store,
+		queue:     make(chan models.ProductView, cfg.QueueSize),
+		done:      make(chan struct{}),
+	}
+}
+
+// Track enqueues a view of every SKU in skus. The trace ID is taken from ctx
+// so that analytics can be joined with the request trace.
+func (t *Tracker) Track(ctx context.Context, userID string, skus []int64) {
+	var traceID string
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		traceID = sc.TraceID().String()
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		ViewsDropped.Add(float64(len(skus)))
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, sku := range skus {
+		view := models.ProductView{
+			Type:     models.EventProductViewed,
+			UserID:   userID,
+			SKU:      sku,
+			ViewedAt: now,
+			TraceID:  traceID,
+		}
+
+		select {
+		case t.queue <- view:
+			ViewsTracked.Inc()
+		default:
+			ViewsDropped.Inc()
+		}
+	}
+}
+
+// Run flushes queued views until Close is called.
+func (t *Tracker) Run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.ProductView, 0, t.cfg.BatchSize)
+	for {
+		select {
+		case view, ok := <-t.queue:
+			if !ok {
+				t.flush(batch)
+				return
+			}
+			batch = append(batch, view)
+			if len(batch) >= t.cfg.BatchSize {
+				t.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			t.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// Close stops accepting views and waits until the queue is flushed or ctx is
+// done. Views tracked after Close, e.g. by handlers still running when the
+// HTTP server's shutdown timed out, are dropped.
+func (t *Tracker) Close(ctx context.Context) error {
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Tracker) flush(batch []models.ProductView) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := t.store.RecordViews(ctx, batch, t.cfg.RecentLimit, t.cfg.RecentTTL); err != nil {
+		log.Printf("failed to record %d views in Redis: %v", len(batch), err)
+		ViewsFlushErrors.WithLabelValues("redis").Inc()
+	}
+
+	if t.publisher == nil {
+		return
+	}
+	if err := t.publisher.Publish(ctx, batch); err != nil {
+		log.Printf("failed to publish %d product_viewed events: %v", len(batch), err)
+		ViewsFlushErrors.WithLabelValues("kafka").Inc()
+	}
+}
@@ -0,0 +1,79 @@
+package views
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Riter/E-Shop/internal/config"
+	"github.com/Riter/E-Shop/internal/models"
+)
+
+type recordingStore struct {
+	mu      sync.Mutex
+	batches [][]models.ProductView
+}
+
+func (s *recordingStore) RecordViews(_ context.Context, views []models.ProductView, _ int, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]models.ProductView(nil), views...))
+	return nil
+}
+
+func TestTrackerFlushesInBatches(t *testing.T) {
+	cfg := &config.ViewsConfig{QueueSize: 10, BatchSize: 2, FlushInterval: time.Hour}
+	store := &recordingStore{}
+	tracker := NewTracker(cfg, nil, store)
+	go tracker.Run()
+
+	tracker.Track(context.Background(), "u1", []int64{1, 2, 3})
+	if err := tracker.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.batches) != 2 || len(store.batches[0]) != 2 || len(store.batches[1]) != 1 {
+		t.Fatalf("expected batches of 2 and 1 views, got %v", store.batches)
+	}
+	if v := store.batches[0][0]; v.Type != models.EventProductViewed || v.UserID != "u1" || v.SKU != 1 {
+		t.Errorf("unexpected view %+v", v)
+	}
+}
+
+func TestTrackerDropsWhenQueueIsFull(t *testing.T) {
+	cfg := &config.ViewsConfig{QueueSize: 1, BatchSize: 10, FlushInterval: time.Hour}
+	store := &recordingStore{}
+	tracker := NewTracker(cfg, nil, store)
+
+	// Run is not started yet, so only the first view fits into the queue.
+	tracker.Track(context.Background(), "", []int64{1, 2})
+	go tracker.Run()
+	if err := tracker.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.batches) != 1 || len(store.batches[0]) != 1 {
+		t.Fatalf("expected one view to be recorded, got %v", store.batches)
+	}
+}
+
+func TestTrackAfterCloseIsDropped(t *testing.T) {
+	cfg := &config.ViewsConfig{QueueSize: 10, BatchSize: 10, FlushInterval: time.Hour}
+	store := &recordingStore{}
+	tracker := NewTracker(cfg, nil, store)
+	go tracker.Run()
+
+	if err := tracker.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// A handler that outlived the server shutdown must not panic.
+	tracker.Track(context.Background(), "u1", []int64{1})
+	if err := tracker.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.batches) != 0 {
+		t.Fatalf("expected no views to be recorded, got %v", store.batches)
+	}
+}
@@ -0,0 +1,244 @@
+package warmup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Riter/E-Shop/common/codec"
+	"github.com/Riter/E-Shop/internal/config"
+	"github.com/Riter/E-Shop/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	prometheus.MustRegister(WarmupInProgress, WarmupTargetSKUs, WarmupLoadedSKUs, WarmupRuns)
+}
+
+var (
+	WarmupInProgress = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cache_warmup_in_progress",
+		Help: "1 while a cache warm-up is running",
+	})
+
+	WarmupTargetSKUs = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cache_warmup_target_skus",
+		Help: "number of SKUs selected by the current or last warm-up",
+	})
+
+	WarmupLoadedSKUs = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cache_warmup_loaded_skus",
+		Help: "number of products written to the cache by the current or last warm-up",
+	})
+
+	WarmupRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_warmup_runs_total",
+		Help: "number of finished warm-ups by result",
+	}, []string{"result"})
+)
+
+// MarkerKey is written after a successful warm-up. It has no TTL, so its
+// absence means Redis lost its data and the cache has to be warmed again.
+const MarkerKey = "cache:warmed"
+
+// LockKey is held by the replica that is warming the cache, so a flush seen
+// by every replica at once leads to a single warm-up.
+const LockKey = "cache:warmup:lock"
+
+// ErrAlreadyRunning is returned while a warm-up runs on this or another
+// replica.
+var ErrAlreadyRunning = errors.New("warm-up is already running")
+
+type Source interface {
+	GetProductsByIDs(ctx context.Context, skus []int64) ([]models.ProductResponse, error)
+	NewestProductIDs(ctx context.Context, n int) ([]int64, error)
+}
+
+type Cacher interface {
+	Set(ctx context.Context, mset map[string]string, expiration time.Duration)
+}
+
+type Store interface {
+	TopViewed(ctx context.Context, n int) ([]int64, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	SetPersistent(ctx context.Context, key, value string) error
+	Lock(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	Unlock(ctx context.Context, key, token string) error
+}
+
+type Warmer struct {
+	cfg    *config.WarmupConfig
+	source Source
+	cacher Cacher
+	store  Store
+	codec  codec.Codec
+
+	running atomic.Bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func New(cfg *config.WarmupConfig, source Source, cacher Cacher, store Store, valueCodec codec.Codec) *Warmer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Warmer{
+		cfg:    cfg,
+		source: source,
+		cacher: cacher,
+		store:  store,
+		codec:  valueCodec,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start runs a warm-up in the background. It returns ErrAlreadyRunning if
+// one is in progress here or another replica holds LockKey.
+func (w *Warmer) Start() error {
+	if !w.running.CompareAndSwap(false, true) {
+		return ErrAlreadyRunning
+	}
+
+	token := newLockToken()
+	locked, err := w.store.Lock(w.ctx, LockKey, token, w.cfg.LockTTL)
+	if err != nil || !locked {
+		w.running.Store(false)
+		if err != nil {
+			return fmt.Errorf("take warm-up lock: %w", err)
+		}
+		return ErrAlreadyRunning
+	}
+
+	go func() {
+		defer w.running.Store(false)
+		defer w.unlock(token)
+		if err := w.run(w.ctx); err != nil {
+			log.Printf("cache warm-up failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// unlock releases LockKey even after Close, so a restarted replica does not
+// wait for the lock to expire.
+func (w *Warmer) unlock(token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.store.Unlock(ctx, LockKey, token); err != nil {
+		log.Printf("cache warm-up: failed to release lock: %v", err)
+	}
+}
+
+// Watch starts a warm-up whenever the marker key disappears from Redis,
+// which covers both the first start and a flush or restart of Redis.
+func (w *Warmer) Watch() {
+	ticker := time.NewTicker(w.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		exists, err := w.store.Exists(w.ctx, MarkerKey)
+		if err != nil {
+			log.Printf("cache warm-up: failed to check marker: %v", err)
+		} else if !exists {
+			if err := w.Start(); err == nil {
+				log.Println("cache warm-up started: marker key is missing")
+			}
+		}
+
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops Watch and interrupts a running warm-up.
+func (w *Warmer) Close() {
+	w.cancel()
+}
+
+func (w *Warmer) run(ctx context.Context) (err error) {
+	WarmupInProgress.Set(1)
+	WarmupLoadedSKUs.Set(0)
+	defer func() {
+		WarmupInProgress.Set(0)
+		if err != nil {
+			WarmupRuns.WithLabelValues("error").Inc()
+		} else {
+			WarmupRuns.WithLabelValues("success").Inc()
+		}
+	}()
+
+	skus, err := w.rank(ctx)
+	if err != nil {
+		return fmt.Errorf("rank products: %w", err)
+	}
+	WarmupTargetSKUs.Set(float64(len(skus)))
+	log.Printf("cache warm-up: loading %d products", len(skus))
+
+	ticker := time.NewTicker(w.cfg.BatchInterval)
+	defer ticker.Stop()
+
+	for start := 0; start < len(skus); start += w.cfg.BatchSize {
+		end := min(start+w.cfg.BatchSize, len(skus))
+
+		products, err := w.source.GetProductsByIDs(ctx, skus[start:end])
+		if err != nil {
+			return fmt.Errorf("load batch: %w", err)
+		}
+
+		mset := make(map[string]string, len(products))
+		for _, p := range products {
+			raw, err := codec.Encode(w.codec, p)
+			if err != nil {
+				return fmt.Errorf("encode product %d: %w", p.ID, err)
+			}
+			mset[strconv.Itoa(p.ID)] = raw
+		}
+		w.cacher.Set(ctx, mset, w.cfg.TTL)
+		WarmupLoadedSKUs.Add(float64(len(mset)))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	if err := w.store.SetPersistent(ctx, MarkerKey, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("set marker: %w", err)
+	}
+	log.Printf("cache warm-up finished: %d products", len(skus))
+	return nil
+}
+
+// rank picks the SKUs to preload. View counts live in Redis too, so after a
+// flush they may be gone; the newest products are used as a fallback then.
+func (w *Warmer) rank(ctx context.Context) ([]int64, error) {
+	switch w.cfg.Source {
+	case "list":
+		skus := w.cfg.SKUs
+		if len(skus) > w.cfg.TopN {
+			skus = skus[:w.cfg.TopN]
+		}
+		return skus, nil
+	case "views":
+		skus, err := w.store.TopViewed(ctx, w.cfg.TopN)
+		if err != nil || len(skus) > 0 {
+			return skus, err
+		}
+	}
+	return w.source.NewestProductIDs(ctx, w.cfg.TopN)
+}
+
+func newLockToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
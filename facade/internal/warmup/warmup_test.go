@@ -0,0 +1,153 @@
+package warmup
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Riter/E-Shop/common/codec"
+	"github.com/Riter/E-Shop/internal/config"
+	"github.com/Riter/E-Shop/internal/models"
+)
+
+type fakeSource struct {
+	newest []int64
+}
+
+func (s *fakeSource) GetProductsByIDs(ctx context.Context, skus []int64) ([]models.ProductResponse, error) {
+	products := make([]models.ProductResponse, 0, len(skus))
+	for _, sku := range skus {
+		products = append(products, models.ProductResponse{ID: int(sku)})
+	}
+	return products, nil
+}
+
+func (s *fakeSource) NewestProductIDs(ctx context.Context, n int) ([]int64, error) {
+	return s.newest[:min(n, len(s.newest))], nil
+}
+
+type fakeCacher struct{}
+
+func (fakeCacher) Set(ctx context.Context, mset map[string]string, expiration time.Duration) {}
+
+type fakeStore struct {
+	mu     sync.Mutex
+	viewed []int64
+	keys   map[string]string
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{keys: make(map[string]string)}
+}
+
+func (s *fakeStore) TopViewed(ctx context.Context, n int) ([]int64, error) {
+	return s.viewed[:min(n, len(s.viewed))], nil
+}
+
+func (s *fakeStore) Exists(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.keys[key]
+	return ok, nil
+}
+
+func (s *fakeStore) SetPersistent(ctx context.Context, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = value
+	return nil
+}
+
+func (s *fakeStore) Lock(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[key]; ok {
+		return false, nil
+	}
+	s.keys[key] = token
+	return true, nil
+}
+
+func (s *fakeStore) Unlock(ctx context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys[key] == token {
+		delete(s.keys, key)
+	}
+	return nil
+}
+
+func newTestWarmer(source *fakeSource, store *fakeStore, cfg config.WarmupConfig) *Warmer {
+	cfg.BatchSize = 10
+	cfg.BatchInterval = time.Millisecond
+	cfg.LockTTL = time.Minute
+	return New(&cfg, source, fakeCacher{}, store, codec.JSON{})
+}
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.WarmupConfig
+		viewed []int64
+		want   []int64
+	}{
+		{"newest", config.WarmupConfig{Source: "newest", TopN: 2}, []int64{9}, []int64{5, 4}},
+		{"views", config.WarmupConfig{Source: "views", TopN: 2}, []int64{9, 8, 7}, []int64{9, 8}},
+		{"views fall back to newest", config.WarmupConfig{Source: "views", TopN: 2}, nil, []int64{5, 4}},
+		{"list", config.WarmupConfig{Source: "list", TopN: 2, SKUs: []int64{1, 2, 3}}, nil, []int64{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			store.viewed = tt.viewed
+			w := newTestWarmer(&fakeSource{newest: []int64{5, 4, 3}}, store, tt.cfg)
+
+			got, err := w.rank(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStartSkipsWhileAnotherReplicaHoldsLock(t *testing.T) {
+	store := newFakeStore()
+	store.keys[LockKey] = "other-replica"
+	w := newTestWarmer(&fakeSource{}, store, config.WarmupConfig{Source: "newest", TopN: 10})
+	defer w.Close()
+
+	if err := w.Start(); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("expected ErrAlreadyRunning, got %v", err)
+	}
+	if w.running.Load() {
+		t.Error("warmer must not stay marked as running")
+	}
+}
+
+func TestStartReleasesLockAfterWarmup(t *testing.T) {
+	store := newFakeStore()
+	w := newTestWarmer(&fakeSource{newest: []int64{1, 2}}, store, config.WarmupConfig{Source: "newest", TopN: 10})
+	defer w.Close()
+
+	if err := w.Start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for w.running.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if ok, _ := store.Exists(context.Background(), MarkerKey); !ok {
+		t.Error("expected marker to be set after warm-up")
+	}
+	if ok, _ := store.Exists(context.Background(), LockKey); ok {
+		t.Error("expected lock to be released after warm-up")
+	}
+}
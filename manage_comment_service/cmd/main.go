@@ -1,7 +1,9 @@
 package main
 
 import (
+	"comments_service/internal/config"
 	"comments_service/internal/db"
+	"comments_service/internal/events"
 	"comments_service/internal/handler"
 	"comments_service/internal/repository"
 	"comments_service/internal/service"
@@ -51,7 +53,17 @@ func main() {
 
 	
 	commentRepo := repository.NewCommentRepository(db)
-	commentService := service.NewCommentService(commentRepo, db)
+	var publisher service.EventPublisher
+	kafkaConfig := config.LoadKafkaConfig()
+	if len(kafkaConfig.Brokers) > 0 {
+		p, err := events.NewPublisher(kafkaConfig.Brokers, kafkaConfig.Topic)
+		if err != nil {
+			log.Fatalf("ошибка подключения к Kafka: %v", err)
+		}
+		defer p.Close()
+		publisher = p
+	}
+	commentService := service.NewCommentService(commentRepo, db, publisher)
 	commentHandler := handler.NewCommentHandler(commentService)
 
 	
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+}
+
+// LoadKafkaConfig читает kafka.env. Пустой KAFKA_BROKERS отключает
+// публикацию событий.
+func LoadKafkaConfig() *KafkaConfig {
+	err := godotenv.Load(findConfigFile("kafka.env"))
+	if err != nil {
+		log.Fatalf("ошибка при считывании .env файла: %v", err)
+	}
+
+	var brokers []string
+	if s := os.Getenv("KAFKA_BROKERS"); s != "" {
+		brokers = strings.Split(s, ",")
+	}
+
+	topic := os.Getenv("KAFKA_COMMENTS_TOPIC")
+	if topic == "" {
+		topic = "product-comments"
+	}
+
+	return &KafkaConfig{Brokers: brokers, Topic: topic}
+}
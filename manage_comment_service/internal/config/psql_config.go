@@ -21,7 +21,7 @@ type PsqlConfig struct {
 	DBMaxConnLifeTime int
 }
 
-func findConfigFile() string {
+func findConfigFile(name string) string {
 	
 	dir, err := os.Getwd()
 	if err != nil {
@@ -30,7 +30,7 @@ func findConfigFile() string {
 
 	
 	for {
-		configPath := filepath.Join(dir, "environment", "psql.env")
+		configPath := filepath.Join(dir, "environment", name)
 		if _, err := os.Stat(configPath); err == nil {
 			return configPath
 		}
@@ -39,14 +39,14 @@ func findConfigFile() string {
 		parent := filepath.Dir(dir)
 		if parent == dir {
 			
-			log.Fatal("файл конфигурации psql.env не найден")
+			log.Fatalf("файл конфигурации %s не найден", name)
 		}
 		dir = parent
 	}
 }
 
 func LoadConfig() *PsqlConfig {
-	configPath := findConfigFile()
+	configPath := findConfigFile("psql.env")
 	err := godotenv.Load(configPath)
 	if err != nil {
 		log.Fatalf("ошибка при считывании .env файла: %v", err)
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"comments_service/internal/models"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Publisher отправляет события комментариев в Kafka. Ключ сообщения - ID
+// товара, поэтому события одного товара читаются по порядку.
+type Publisher struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func NewPublisher(brokers []string, topic string) (*Publisher, error) {
+	config := sarama.NewConfig()
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	config.Producer.Timeout = 5 * time.Second
+
+	producer, err := sarama.NewSyncProducer(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka producer: %w", err)
+	}
+	return &Publisher{producer: producer, topic: topic}, nil
+}
+
+func (p *Publisher) Publish(event models.CommentEvent) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode comment event: %w", err)
+	}
+
+	_, _, err = p.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: p.topic,
+		Key:   sarama.StringEncoder(strconv.FormatInt(event.ProductID, 10)),
+		Value: sarama.ByteEncoder(value),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish comment event: %w", err)
+	}
+	return nil
+}
+
+func (p *Publisher) Close() error {
+	return p.producer.Close()
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -26,6 +27,7 @@ func (h *CommentHandler) RegisterRoutes(r chi.Router) {
 		r.Get("/comments/{id}", h.getComment)
 		r.Get("/products/{productID}/comments", h.getProductComments)
 		r.Get("/products/{productID}/rating", h.getProductRating)
+		r.Get("/ratings", h.getProductRatings)
 	})
 
 	
@@ -123,6 +125,41 @@ func (h *CommentHandler) getProductRating(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(rating)
 }
 
+// maxRatingsBatch ограничивает число товаров в одном запросе /ratings.
+const maxRatingsBatch = 1000
+
+func (h *CommentHandler) getProductRatings(w http.ResponseWriter, r *http.Request) {
+	raw := r.URL.Query().Get("product_ids")
+	if raw == "" {
+		http.Error(w, "Missing product_ids parameter", http.StatusBadRequest)
+		return
+	}
+
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxRatingsBatch {
+		http.Error(w, "Too many product IDs", http.StatusBadRequest)
+		return
+	}
+
+	productIDs := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		productID, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid product ID", http.StatusBadRequest)
+			return
+		}
+		productIDs = append(productIDs, productID)
+	}
+
+	ratings, err := h.service.GetProductRatings(productIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(ratings)
+}
+
 func (h *CommentHandler) updateComment(w http.ResponseWriter, r *http.Request) {
 	
 	userIDStr := r.Header.Get("X-User-ID")
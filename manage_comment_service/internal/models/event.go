@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+const (
+	EventCommentCreated = "comment_created"
+	EventCommentUpdated = "comment_updated"
+	EventCommentDeleted = "comment_deleted"
+)
+
+// CommentEvent публикуется после каждого изменения комментария, чтобы
+// другие сервисы (например, поиск) могли пересчитать рейтинг товара.
+type CommentEvent struct {
+	Type       string    `json:"type"`
+	CommentID  int64     `json:"comment_id"`
+	ProductID  int64     `json:"product_id"`
+	Rating     int       `json:"rating"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
@@ -5,19 +5,36 @@ import (
 	"comments_service/internal/repository"
 	"database/sql"
 	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+// EventPublisher получает события об изменении комментариев.
+type EventPublisher interface {
+	Publish(event models.CommentEvent) error
+}
+
 type CommentService struct {
-	repo *repository.CommentRepository
-	db   *sql.DB
+	repo   *repository.CommentRepository
+	db     *sql.DB
+	events EventPublisher
 }
 
-func NewCommentService(repo *repository.CommentRepository, db *sql.DB) *CommentService {
-	return &CommentService{repo: repo, db: db}
+// NewCommentService создаёт сервис. events может быть nil, тогда события не
+// публикуются.
+func NewCommentService(repo *repository.CommentRepository, db *sql.DB, events EventPublisher) *CommentService {
+	return &CommentService{repo: repo, db: db, events: events}
 }
 
 func (s *CommentService) CreateComment(comment models.CreateCommentDTO) (int64, error) {
-	return s.repo.Create(comment)
+	id, err := s.repo.Create(comment)
+	if err != nil {
+		return 0, err
+	}
+	s.publish(models.EventCommentCreated, id, comment.ProductID, comment.Rating)
+	return id, nil
 }
 
 func (s *CommentService) GetComment(id int64) (*models.Comment, error) {
@@ -29,11 +46,50 @@ func (s *CommentService) GetProductComments(productID int64) ([]*models.Comment,
 }
 
 func (s *CommentService) UpdateComment(id int64, comment models.UpdateCommentDTO) error {
-	return s.repo.Update(id, comment)
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Update(id, comment); err != nil {
+		return err
+	}
+	if existing != nil {
+		s.publish(models.EventCommentUpdated, id, existing.ProductID, comment.Rating)
+	}
+	return nil
 }
 
 func (s *CommentService) DeleteComment(id int64) error {
-	return s.repo.Delete(id)
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	if existing != nil {
+		s.publish(models.EventCommentDeleted, id, existing.ProductID, existing.Rating)
+	}
+	return nil
+}
+
+// publish не возвращает ошибку: комментарий уже сохранён. Если событие
+// потерялось, поиск получит новый рейтинг при следующем полном обновлении
+// сигналов (SEARCH_SYNC_SIGNALS_INTERVAL в elastic_search_service).
+func (s *CommentService) publish(eventType string, commentID, productID int64, rating int) {
+	if s.events == nil {
+		return
+	}
+	err := s.events.Publish(models.CommentEvent{
+		Type:       eventType,
+		CommentID:  commentID,
+		ProductID:  productID,
+		Rating:     rating,
+		OccurredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("не удалось опубликовать событие %s: %v", eventType, err)
+	}
 }
 
 func (s *CommentService) GetProductRating(productID int64) (*models.ProductRating, error) {
@@ -53,3 +109,40 @@ func (s *CommentService) GetProductRating(productID int64) (*models.ProductRatin
 
 	return &rating, nil
 }
+
+// GetProductRatings возвращает рейтинги сразу для нескольких товаров. Товары
+// без отзывов тоже попадают в ответ с нулевым рейтингом.
+func (s *CommentService) GetProductRatings(productIDs []int64) ([]models.ProductRating, error) {
+	rows, err := s.db.Query(`
+		SELECT product_id, AVG(rating), COUNT(*)
+		FROM comments
+		WHERE product_id = ANY($1)
+		GROUP BY product_id
+	`, pq.Array(productIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product ratings: %w", err)
+	}
+	defer rows.Close()
+
+	found := make(map[int64]models.ProductRating, len(productIDs))
+	for rows.Next() {
+		var rating models.ProductRating
+		if err := rows.Scan(&rating.ProductID, &rating.AverageRating, &rating.ReviewCount); err != nil {
+			return nil, fmt.Errorf("failed to scan product rating: %w", err)
+		}
+		found[rating.ProductID] = rating
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get product ratings: %w", err)
+	}
+
+	ratings := make([]models.ProductRating, 0, len(productIDs))
+	for _, id := range productIDs {
+		rating, ok := found[id]
+		if !ok {
+			rating.ProductID = id
+		}
+		ratings = append(ratings, rating)
+	}
+	return ratings, nil
+}
@@ -19,7 +19,7 @@ func TestCommentCRUD(t *testing.T) {
 
 	
 	repo := repository.NewCommentRepository(db)
-	commentService := service.NewCommentService(repo, db)
+	commentService := service.NewCommentService(repo, db, nil)
 
 	
 	t.Run("Create Comment", func(t *testing.T) {
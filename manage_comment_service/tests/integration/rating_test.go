@@ -103,5 +103,26 @@ func TestProductRating(t *testing.T) {
 			require.InDelta(t, expectedAverage, rating.AverageRating, 0.01)
 			require.Equal(t, int64(5), rating.ReviewCount)
 		})
+
+		// Тест 4: Рейтинги нескольких товаров одним запросом
+		t.Run("Batch Ratings", func(t *testing.T) {
+			resp, err := http.Get(fmt.Sprintf("http://localhost:30333/ratings?product_ids=%d,%d", productID, int64(999999)))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			fmt.Printf("Получение рейтингов товаров - Статус: %d\n", resp.StatusCode)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var ratings []models.ProductRating
+			err = json.NewDecoder(resp.Body).Decode(&ratings)
+			require.NoError(t, err)
+
+			require.Len(t, ratings, 2)
+			require.Equal(t, productID, ratings[0].ProductID)
+			require.Equal(t, int64(5), ratings[0].ReviewCount)
+			// У товара без отзывов нулевой рейтинг
+			require.Equal(t, int64(999999), ratings[1].ProductID)
+			require.Equal(t, int64(0), ratings[1].ReviewCount)
+		})
 	})
 }
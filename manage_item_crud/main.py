@@ -5,7 +5,8 @@ import os
 from typing import Dict
 import threading
 import time
-from datetime import datetime
+import uuid
+from datetime import datetime, timezone
 
 from fastapi import FastAPI, HTTPException, status
 from aiokafka import AIOKafkaProducer
@@ -48,6 +49,9 @@ _memory_store: Dict[int, Item] = {}
 
 def json_datetime_serializer(obj):
     if isinstance(obj, datetime):
+        # Naive datetimes are taken as UTC, so consumers always get an offset.
+        if obj.tzinfo is None:
+            obj = obj.replace(tzinfo=timezone.utc)
         return obj.isoformat()
     raise TypeError(f"Object of type {obj.__class__.__name__} is not JSON serializable")
 
@@ -76,9 +80,20 @@ async def shutdown_event():
 
 
 
+# Version of the product event envelope, see common/events in the Go services.
+EVENT_SCHEMA_VERSION = 1
+
+
 async def _publish_event(payload: dict, partition: int = PARTITION_RAW) -> None:
     if not _kafka_producer:
         raise RuntimeError("Kafka producer not started")
+
+    payload = {
+        "schema_version": EVENT_SCHEMA_VERSION,
+        "event_id": str(uuid.uuid4()),
+        "occurred_at": datetime.now(timezone.utc),
+        **payload,
+    }
     
     await _kafka_producer.send_and_wait(
         TOPIC,
@@ -123,7 +138,7 @@ async def change_item(item_id: int, req: ChangeItemRequest):
     await _publish_event({
         "operation_type": req.operation_type,
         "item_id": item_id,
-        "item": req.item.model_dump()
+        "item": _memory_store[item_id].model_dump()
     })
     ITEMS_UPDATED.inc()
     REQUEST_LATENCY.observe(time.time() - start_time)
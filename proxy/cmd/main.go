@@ -16,6 +16,9 @@ import (
 	"github.com/Riter/E-Shop/proxy/internal/config"
 )
 
+// userIDHeader - ID пользователя для facade и сервиса поиска.
+const userIDHeader = "X-User-ID"
+
 func main() {
     cfg := config.LoadConfig()
 
@@ -64,7 +67,12 @@ func main() {
         //     return
         // }
 
-        // 5. Настраиваем прокси
+        // 5. X-User-ID сервисы за прокси считают ID пользователя. Пока прокси
+        // не проверяет JWT, заголовок от клиента не пробрасывается, иначе
+        // любой клиент мог бы представиться другим пользователем.
+        r.Header.Del(userIDHeader)
+
+        // 6. Настраиваем прокси
         proxy := httputil.NewSingleHostReverseProxy(proxyURL)
         proxy.Director = func(req *http.Request) {
             req.URL.Scheme = proxyURL.Scheme